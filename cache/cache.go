@@ -2,16 +2,44 @@ package cache
 
 import "time"
 
-// Repository is an interface for the cache
+const (
+	// StatePending is an entry that was claimed by a worker and is currently being posted. If it's still pending long
+	// after that, the worker didn't get to confirm the post.
+	StatePending = "pending"
+	// StateSent is an entry that was successfully posted
+	StateSent = "sent"
+	// StateFailed is an entry where posting failed, it can be claimed again to retry
+	StateFailed = "failed"
+	// StateSkipped is an entry that was already in the feed when the notification service was first seen, it's never
+	// posted
+	StateSkipped = "skipped"
+)
+
+// Repository is an interface for the cache. Entries are scoped by the name of the feed they are from, so items of
+// different feeds can have the same key.
 type Repository interface {
-	Get(key string, notificationService string) (*Entry, bool, error)
-	Set(entry Entry) error
-	EntryExists(date time.Time, notificationService string) (bool, error)
+	Get(feed string, key string, notificationService string) (*Entry, bool, error)
+	Claim(feed string, key string, notificationService string, date time.Time, staleBefore time.Time) (bool, error)
+	MarkSent(feed string, key string, notificationService string, remoteID string, remoteURL string, publishedAt time.Time) error
+	MarkFailed(feed string, key string, notificationService string, postErr error) error
+	CountSince(feed string, notificationService string, since time.Time) (int, time.Time, error)
+	LastPublished(feed string, notificationService string) (time.Time, bool, error)
+	Recover(staleBefore time.Time) (int64, error)
+	Seen(feed string, notificationService string) (bool, error)
+	Skip(feed string, key string, notificationService string, date time.Time) error
 }
 
 // Entry is a struct for a cache entry
 type Entry struct {
-	Key                 string `db:"key"`
-	NotificationService string `db:"notification_service"`
-	Date                string `db:"date"`
+	Feed                string    `db:"feed"`
+	Key                 string    `db:"key"`
+	NotificationService string    `db:"notification_service"`
+	Date                time.Time `db:"date"`
+	State               string    `db:"state"`
+	Attempts            int       `db:"attempts"`
+	LastError           string    `db:"last_error"`
+	UpdatedAt           time.Time `db:"updated_at"`
+	RemoteID            string    `db:"remote_id"`
+	RemoteURL           string    `db:"remote_url"`
+	PublishedAt         time.Time `db:"published_at"`
 }
@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/dewey/webhook-receiver/database"
 	"github.com/go-kit/log"
 	"github.com/jmoiron/sqlx"
-	"time"
 )
 
 type repository struct {
@@ -20,9 +23,9 @@ func NewRepository(l log.Logger, db *sqlx.DB) (*repository, error) {
 }
 
 // Get returns a cache entry for a given key
-func (s *repository) Get(key string, notificationService string) (*Entry, bool, error) {
+func (s *repository) Get(feed string, key string, notificationService string) (*Entry, bool, error) {
 	var entry Entry
-	err := s.db.Get(&entry, "SELECT * FROM cache WHERE key=$1 AND notification_service=$2", key, notificationService)
+	err := s.db.Get(&entry, "SELECT * FROM cache WHERE feed=$1 AND key=$2 AND notification_service=$3", feed, key, notificationService)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
 			return nil, false, nil
@@ -33,22 +36,118 @@ func (s *repository) Get(key string, notificationService string) (*Entry, bool,
 	return &entry, true, nil
 }
 
-// Set sets a cache entry
-func (s *repository) Set(entry Entry) error {
-	_, err := s.db.NamedExec("INSERT INTO cache (key, notification_service, date) VALUES (:key, :notification_service, :date)",
+// Claim marks an entry as pending before it's posted at the given time, which is stored as the publish time until it's
+// confirmed by MarkSent. It returns false if the entry is sent or pending, only new and failed entries can be claimed
+// and pending ones that were claimed before staleBefore, whose post was never confirmed. This happens in a single
+// statement, so two workers can't claim the same entry.
+func (s *repository) Claim(feed string, key string, notificationService string, date time.Time, staleBefore time.Time) (bool, error) {
+	res, err := s.db.NamedExec(`INSERT INTO cache (feed, key, notification_service, date, state, attempts, last_error, updated_at, published_at)
+		VALUES (:feed, :key, :notification_service, :date, :state, 1, '', :updated_at, :published_at)
+		ON CONFLICT (feed, key, notification_service) DO UPDATE
+		SET state=excluded.state, attempts=cache.attempts+1, date=excluded.date, updated_at=excluded.updated_at, published_at=excluded.published_at
+		WHERE cache.state=:failed OR (cache.state=:state AND cache.updated_at < :stale_before)`,
 		map[string]interface{}{
-			"key":                  entry.Key,
-			"notification_service": entry.NotificationService,
-			"date":                 entry.Date,
+			"feed":                 feed,
+			"key":                  key,
+			"notification_service": notificationService,
+			"date":                 database.FormatTime(date),
+			"published_at":         database.FormatTime(date),
+			"state":                StatePending,
+			"updated_at":           database.FormatTime(time.Now()),
+			"failed":               StateFailed,
+			"stale_before":         database.FormatTime(staleBefore),
 		})
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// MarkSent marks a claimed entry as successfully posted and stores where it was published
+func (s *repository) MarkSent(feed string, key string, notificationService string, remoteID string, remoteURL string, publishedAt time.Time) error {
+	_, err := s.db.Exec("UPDATE cache SET state=$1, last_error='', updated_at=$2, remote_id=$3, remote_url=$4, published_at=$5 WHERE feed=$6 AND key=$7 AND notification_service=$8",
+		StateSent, database.FormatTime(time.Now()), remoteID, remoteURL, database.FormatTime(publishedAt), feed, key, notificationService)
+	return err
+}
+
+// MarkFailed marks a claimed entry as failed, so it can be claimed again on the next try
+func (s *repository) MarkFailed(feed string, key string, notificationService string, postErr error) error {
+	_, err := s.db.Exec("UPDATE cache SET state=$1, last_error=$2, updated_at=$3 WHERE feed=$4 AND key=$5 AND notification_service=$6",
+		StateFailed, postErr.Error(), database.FormatTime(time.Now()), feed, key, notificationService)
 	return err
 }
 
-// EntryExists checks if a cache entry exists for a specific day and notification service
-func (s *repository) EntryExists(date time.Time, notificationService string) (bool, error) {
-	var count int
-	if err := s.db.Get(&count, "SELECT COUNT(*) FROM cache WHERE date=$1 AND notification_service=$2", date.Format("2006-01-02"), notificationService); err != nil {
+// CountSince returns how many posts a notification service published for a feed since the given time, and when the
+// oldest of them was published. Failed entries don't count, pending ones do as they are being posted right now.
+// Scheduled posts count too, their publish time is in the future.
+func (s *repository) CountSince(feed string, notificationService string, since time.Time) (int, time.Time, error) {
+	var row struct {
+		Count  int    `db:"count"`
+		Oldest string `db:"oldest"`
+	}
+	if err := s.db.Get(&row, "SELECT COUNT(*) AS count, COALESCE(MIN(published_at), '') AS oldest FROM cache WHERE feed=$1 AND notification_service=$2 AND state IN ($3, $4) AND published_at >= $5",
+		feed, notificationService, StatePending, StateSent, database.FormatTime(since)); err != nil {
+		return 0, time.Time{}, err
+	}
+	if row.Oldest == "" {
+		return row.Count, time.Time{}, nil
+	}
+	oldest, err := time.Parse(time.RFC3339, row.Oldest)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("invalid publish time %q", row.Oldest)
+	}
+	return row.Count, oldest, nil
+}
+
+// LastPublished returns when the latest post of a notification service for a feed was or will be published
+func (s *repository) LastPublished(feed string, notificationService string) (time.Time, bool, error) {
+	var last string
+	if err := s.db.Get(&last, "SELECT COALESCE(MAX(published_at), '') FROM cache WHERE feed=$1 AND notification_service=$2 AND state IN ($3, $4)",
+		feed, notificationService, StatePending, StateSent); err != nil {
+		return time.Time{}, false, err
+	}
+	if last == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, last)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid publish time %q", last)
+	}
+	return t, true, nil
+}
+
+// Recover marks entries that were still pending when the process stopped as failed if they were claimed before
+// staleBefore, like Claim does. It returns how many there were. Their posts were never confirmed, but they may have
+// gone through right before the process stopped. Notifiers that can't tell if a post already exists then post the item
+// a second time. Entries claimed more recently are left alone until their claim is stale, as a crash right after a
+// successful post is the most likely reason for them.
+func (s *repository) Recover(staleBefore time.Time) (int64, error) {
+	res, err := s.db.Exec("UPDATE cache SET state=$1, last_error=$2, updated_at=$3 WHERE state=$4 AND updated_at < $5",
+		StateFailed, "interrupted before the post was confirmed", database.FormatTime(time.Now()), StatePending, database.FormatTime(staleBefore))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Seen returns if the notification service has any entries for the feed, in any state
+func (s *repository) Seen(feed string, notificationService string) (bool, error) {
+	var seen bool
+	if err := s.db.Get(&seen, "SELECT EXISTS (SELECT 1 FROM cache WHERE feed=$1 AND notification_service=$2)", feed, notificationService); err != nil {
 		return false, err
 	}
-	return count > 0, nil
+	return seen, nil
+}
+
+// Skip marks an entry as skipped, so it's never posted. Entries that already exist are left as they are.
+func (s *repository) Skip(feed string, key string, notificationService string, date time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO cache (feed, key, notification_service, date, state, attempts, last_error, updated_at, published_at)
+		VALUES ($1, $2, $3, $4, $5, 0, '', $6, $4)
+		ON CONFLICT (feed, key, notification_service) DO NOTHING`,
+		feed, key, notificationService, database.FormatTime(date), StateSkipped, database.FormatTime(time.Now()))
+	return err
 }
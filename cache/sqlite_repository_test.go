@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dewey/webhook-receiver/database"
+	"github.com/dewey/webhook-receiver/database/databasetest"
+	"github.com/dewey/webhook-receiver/schedule"
+	"github.com/go-kit/log"
+	"github.com/pressly/goose/v3"
+)
+
+// newTestRepository returns a repository on a new database, migrated with the migrations of the api
+func newTestRepository(t *testing.T) *repository {
+	t.Helper()
+	r, err := NewRepository(log.NewNopLogger(), databasetest.NewDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func Test_repository_ClaimAndRetry(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	stale := now.Add(-5 * time.Minute)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		claimed, err := r.Claim("blog", "guid-1", "mastodon", now, stale)
+		if err != nil || !claimed {
+			t.Fatalf("attempt %d: Claim() = %v, %v, want claimed", attempt, claimed, err)
+		}
+		// It's pending now, nobody else can claim it
+		if claimed, err := r.Claim("blog", "guid-1", "mastodon", now, stale); err != nil || claimed {
+			t.Fatalf("attempt %d: Claim() of a pending entry = %v, %v, want not claimed", attempt, claimed, err)
+		}
+		if err := r.MarkFailed("blog", "guid-1", "mastodon", errors.New("instance is down")); err != nil {
+			t.Fatal(err)
+		}
+		entry, ok, err := r.Get("blog", "guid-1", "mastodon")
+		if err != nil || !ok {
+			t.Fatalf("Get() = %v, %v", ok, err)
+		}
+		if entry.State != StateFailed || entry.Attempts != attempt || entry.LastError != "instance is down" {
+			t.Errorf("attempt %d: Get() = %+v, want failed after %d attempts", attempt, entry, attempt)
+		}
+	}
+
+	if claimed, err := r.Claim("blog", "guid-1", "mastodon", now, stale); err != nil || !claimed {
+		t.Fatalf("Claim() = %v, %v, want claimed", claimed, err)
+	}
+	if err := r.MarkSent("blog", "guid-1", "mastodon", "123", "https://example.com/123", now); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := r.Claim("blog", "guid-1", "mastodon", now, stale); err != nil || claimed {
+		t.Errorf("Claim() of a sent entry = %v, %v, want not claimed", claimed, err)
+	}
+	entry, _, err := r.Get("blog", "guid-1", "mastodon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.State != StateSent || entry.Attempts != 4 || entry.LastError != "" || entry.RemoteID != "123" {
+		t.Errorf("Get() = %+v, want sent in the fourth attempt", entry)
+	}
+}
+
+func Test_repository_ClaimStale(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	if claimed, err := r.Claim("blog", "guid-1", "mastodon", now, now.Add(-5*time.Minute)); err != nil || !claimed {
+		t.Fatalf("Claim() = %v, %v, want claimed", claimed, err)
+	}
+	// The worker died before it could confirm the post, once the lease is over it can be claimed again
+	if claimed, err := r.Claim("blog", "guid-1", "mastodon", now, now.Add(time.Minute)); err != nil || !claimed {
+		t.Errorf("Claim() of a stale entry = %v, %v, want claimed", claimed, err)
+	}
+}
+
+func Test_repository_Recover(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	for _, key := range []string{"guid-1", "guid-2", "guid-3"} {
+		if _, err := r.Claim("blog", key, "mastodon", now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.MarkSent("blog", "guid-1", "mastodon", "", "", now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claims within the lease are left alone, the post may have gone through right before a crash
+	if recovered, err := r.Recover(now.Add(-time.Hour)); err != nil || recovered != 0 {
+		t.Fatalf("Recover() = %d, %v, want recent claims left alone", recovered, err)
+	}
+	if _, err := r.db.Exec("UPDATE cache SET updated_at=$1 WHERE key='guid-2'", database.FormatTime(now.Add(-2*time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := r.Recover(now.Add(-time.Hour))
+	if err != nil || recovered != 1 {
+		t.Fatalf("Recover() = %d, %v, want 1", recovered, err)
+	}
+	entry, _, err := r.Get("blog", "guid-2", "mastodon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.State != StateFailed {
+		t.Errorf("Get() = %+v, want the interrupted post failed", entry)
+	}
+	if entry, _, err := r.Get("blog", "guid-3", "mastodon"); err != nil || entry.State != StatePending {
+		t.Errorf("Get() = %+v, %v, want the recent claim still pending", entry, err)
+	}
+	if claimed, err := r.Claim("blog", "guid-2", "mastodon", now, now.Add(-time.Hour)); err != nil || !claimed {
+		t.Errorf("Claim() = %v, %v, want the interrupted post claimed again", claimed, err)
+	}
+}
+
+func Test_repository_CountSince(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now().Truncate(time.Second)
+	posts := []struct {
+		feed   string
+		key    string
+		at     time.Time
+		failed bool
+	}{
+		{feed: "blog", key: "guid-1", at: now.Add(-48 * time.Hour)},
+		{feed: "blog", key: "guid-2", at: now.Add(-time.Hour)},
+		{feed: "blog", key: "guid-3", at: now.Add(-time.Minute), failed: true},
+		{feed: "podcast", key: "guid-1", at: now},
+	}
+	for _, p := range posts {
+		if _, err := r.Claim(p.feed, p.key, "mastodon", p.at, now); err != nil {
+			t.Fatal(err)
+		}
+		if p.failed {
+			if err := r.MarkFailed(p.feed, p.key, "mastodon", errors.New("failed")); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := r.MarkSent(p.feed, p.key, "mastodon", "", "", p.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count, oldest, err := r.CountSince("blog", "mastodon", now.Add(-24*time.Hour)); err != nil || count != 1 || !oldest.Equal(now.Add(-time.Hour)) {
+		t.Errorf("CountSince() = %d, %v, %v, want 1, %v", count, oldest, err, now.Add(-time.Hour))
+	}
+	if count, oldest, err := r.CountSince("blog", "mastodon", now.Add(time.Minute)); err != nil || count != 0 || !oldest.IsZero() {
+		t.Errorf("CountSince() = %d, %v, %v, want nothing", count, oldest, err)
+	}
+	last, ok, err := r.LastPublished("blog", "mastodon")
+	if err != nil || !ok || !last.Equal(now.Add(-time.Hour)) {
+		t.Errorf("LastPublished() = %v, %v, %v, want %v", last, ok, err, now.Add(-time.Hour))
+	}
+	if _, ok, err := r.LastPublished("blog", "twitter"); err != nil || ok {
+		t.Errorf("LastPublished() = %v, %v, want nothing published", ok, err)
+	}
+}
+
+func Test_repository_Skip(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	if seen, err := r.Seen("blog", "mastodon"); err != nil || seen {
+		t.Fatalf("Seen() = %v, %v, want not seen", seen, err)
+	}
+	if _, err := r.Claim("blog", "guid-1", "mastodon", now, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"guid-1", "guid-2"} {
+		if err := r.Skip("blog", key, "mastodon", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if seen, err := r.Seen("blog", "mastodon"); err != nil || !seen {
+		t.Errorf("Seen() = %v, %v, want seen", seen, err)
+	}
+	if seen, err := r.Seen("podcast", "mastodon"); err != nil || seen {
+		t.Errorf("Seen() of another feed = %v, %v, want not seen", seen, err)
+	}
+	if entry, _, err := r.Get("blog", "guid-1", "mastodon"); err != nil || entry.State != StatePending {
+		t.Errorf("Get() = %+v, %v, want the claimed entry left as it is", entry, err)
+	}
+	if entry, _, err := r.Get("blog", "guid-2", "mastodon"); err != nil || entry.State != StateSkipped {
+		t.Errorf("Get() = %+v, %v, want skipped", entry, err)
+	}
+	if claimed, err := r.Claim("blog", "guid-2", "mastodon", now, now.Add(time.Hour)); err != nil || claimed {
+		t.Errorf("Claim() of a skipped entry = %v, %v, want not claimed", claimed, err)
+	}
+	if count, _, err := r.CountSince("blog", "mastodon", now.Add(-time.Hour)); err != nil || count != 1 {
+		t.Errorf("CountSince() = %d, %v, want only the claimed entry", count, err)
+	}
+}
+
+func Test_migrateDateTimestamps(t *testing.T) {
+	// Before the migration dates were only the day, in the time zone of the server
+	db := databasetest.NewDBAt(t, 20230620120000)
+	for _, row := range []struct{ key, date string }{
+		{key: "guid-1", date: "2023-06-06"},
+		{key: "guid-2", date: "2023-06-07"},
+		{key: "guid-3", date: "2023-06-07"},
+	} {
+		if _, err := db.Exec("INSERT INTO cache (key, notification_service, date) VALUES ($1, 'mastodon', $2)", row.key, row.date); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	databasetest.MigrateTo(t, db, 20230625120000)
+	var rows []struct {
+		Key         string `db:"key"`
+		Date        string `db:"date"`
+		UpdatedAt   string `db:"updated_at"`
+		PublishedAt string `db:"published_at"`
+	}
+	// Concatenating keeps the driver from parsing the datetime columns, we want to see what's stored
+	if err := db.Select(&rows, "SELECT key, date || '' AS date, updated_at || '' AS updated_at, published_at || '' AS published_at FROM cache ORDER BY key"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2023-06-06T00:00:00Z", "2023-06-07T00:00:00Z", "2023-06-07T00:00:00Z"}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row.Date != want[i] || row.UpdatedAt != want[i] || row.PublishedAt != want[i] {
+			t.Errorf("%s: date = %q, updated_at = %q, published_at = %q, want %q", row.Key, row.Date, row.UpdatedAt, row.PublishedAt, want[i])
+		}
+	}
+
+	// Days start at midnight in the configured time zone, the converted rows count towards the day they were posted on
+	databasetest.MigrateTo(t, db, goose.MaxVersion)
+	r, err := NewRepository(log.NewNopLogger(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cadence, err := schedule.ParseCadence("posts=2;per=day", berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   time.Time
+		want int
+	}{
+		{at: time.Date(2023, 6, 6, 18, 0, 0, 0, berlin), want: 3},
+		{at: time.Date(2023, 6, 7, 18, 0, 0, 0, berlin), want: 2},
+		{at: time.Date(2023, 6, 8, 18, 0, 0, 0, berlin), want: 0},
+	}
+	for _, tt := range tests {
+		count, _, err := r.CountSince("default", "mastodon", cadence.Since(tt.at))
+		if err != nil || count != tt.want {
+			t.Errorf("CountSince(%v) = %d, %v, want %d", cadence.Since(tt.at), count, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3"
+)
+
+// defaultFeed is the name of the feed configured with the main flags. It's the name the cache entries from before we
+// supported multiple feeds have.
+const defaultFeed = "default"
+
+// feedNamePattern is what feed names can look like, they end up in the names of environment variables
+var feedNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// feedFlags are the settings of an additional feed
+type feedFlags struct {
+	name      string
+	url       *string
+	hookToken *string
+	notifiers *notifierFlags
+}
+
+// newFeedFlags defines the settings of an additional feed on the flag set
+func newFeedFlags(fs *flag.FlagSet, name string) *feedFlags {
+	return &feedFlags{
+		name:      name,
+		url:       fs.String("feed-url", "", "the direct url to the feed index"),
+		hookToken: fs.String("hook-token", "", "the secret token for the hook of the feed"),
+		notifiers: newNotifierFlags(fs, name+"-twitter-token.json"),
+	}
+}
+
+// parseFeedFlags reads the settings of an additional feed from environment variables and the config file with the
+// name of the feed as prefix, e.g. WR_PODCAST_FEED_URL or "podcast-feed-url" for the feed "podcast". Environment
+// variables take precedence, like they do for the main flags.
+func parseFeedFlags(name string, configFile string) (*feedFlags, error) {
+	if !feedNamePattern.MatchString(name) || name == defaultFeed {
+		return nil, fmt.Errorf("invalid feed name %q, use lowercase letters, numbers, - and _", name)
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	f := newFeedFlags(fs, name)
+	prefix := "WR_" + strings.ReplaceAll(name, "-", "_")
+	if err := ff.Parse(fs, nil,
+		ff.WithEnvVarPrefix(prefix),
+		ff.WithConfigFile(configFile),
+		ff.WithConfigFileParser(feedConfigParser(fs, name)),
+	); err != nil {
+		return nil, err
+	}
+	if *f.url == "" {
+		return nil, fmt.Errorf("feed %q has no url, set %s_FEED_URL or %s-feed-url in the config file", name, strings.ToUpper(prefix), name)
+	}
+	if *f.hookToken == "" {
+		return nil, fmt.Errorf("feed %q has no hook token, set %s_HOOK_TOKEN or %s-hook-token in the config file", name, strings.ToUpper(prefix), name)
+	}
+	return f, nil
+}
+
+// feedConfigParser reads the settings of a feed from the config file of the main flags, where they are prefixed with
+// the name of the feed. Keys of the main flags and other feeds are skipped.
+func feedConfigParser(fs *flag.FlagSet, name string) ff.ConfigFileParser {
+	return func(r io.Reader, set func(name, value string) error) error {
+		return ff.PlainParser(r, func(key, value string) error {
+			setting := strings.TrimPrefix(key, name+"-")
+			if setting == key || fs.Lookup(setting) == nil {
+				return nil
+			}
+			return set(setting, value)
+		})
+	}
+}
+
+// mainConfigParser reads the main flags from the config file. Settings of additional feeds are skipped, they are read
+// by parseFeedFlags once we know the feeds. Unknown keys are still an error.
+func mainConfigParser(fs *flag.FlagSet) ff.ConfigFileParser {
+	return func(r io.Reader, set func(name, value string) error) error {
+		return ff.PlainParser(r, func(key, value string) error {
+			if fs.Lookup(key) == nil && isFeedSetting(key) {
+				return nil
+			}
+			return set(key, value)
+		})
+	}
+}
+
+// isFeedSetting returns if the key of the config file is the setting of an additional feed, e.g. "podcast-feed-url"
+func isFeedSetting(key string) bool {
+	fs := flag.NewFlagSet("feed", flag.ContinueOnError)
+	newFeedFlags(fs, "feed")
+	var ok bool
+	fs.VisitAll(func(f *flag.Flag) {
+		if name := strings.TrimSuffix(key, "-"+f.Name); name != key && feedNamePattern.MatchString(name) {
+			ok = true
+		}
+	})
+	return ok
+}
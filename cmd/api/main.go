@@ -8,25 +8,30 @@ import (
 	"github.com/dewey/webhook-receiver/cache"
 	"github.com/dewey/webhook-receiver/feed"
 	"github.com/dewey/webhook-receiver/notification"
+	"github.com/dewey/webhook-receiver/queue"
+	"github.com/dewey/webhook-receiver/schedule"
 	"github.com/dewey/webhook-receiver/service/hooklistener"
-	"github.com/dghubble/go-twitter/twitter"
-	"github.com/dghubble/oauth1"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/jmoiron/sqlx"
-	"github.com/mattn/go-mastodon"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/peterbourgon/ff/v3"
 	"github.com/pressly/goose/v3"
 	"net/http"
 	"os"
 	"strings"
+	"time"
+	// The time zone of the posting window has to be loadable in containers without tzdata
+	_ "time/tzdata"
 )
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// dateTimestampsMigration is the version of the migration that converted the dates in the cache from days to timestamps
+const dateTimestampsMigration = 20230625120000
+
 type maxBytesHandler struct {
 	h http.Handler
 	n int64
@@ -40,25 +45,42 @@ func (h *maxBytesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func main() {
 	fs := flag.NewFlagSet("webhook-receiver", flag.ExitOnError)
 	var (
-		environment              = fs.String("environment", "develop", "the environment we are running in")
-		port                     = fs.String("port", "8080", "the port webhook-receiver is running on")
-		twitterConsumerKey       = fs.String("twitter-consumer-key", "", "the twitter consumer key")
-		twitterConsumerSecretKey = fs.String("twitter-consumer-secret-key", "", "the twitter consumer secret key")
-		twitterAccessToken       = fs.String("twitter-access-token", "", "the twitter consumer key")
-		twitterAccessTokenSecret = fs.String("twitter-access-token-secret", "", "the twitter consumer secret key")
-		twitterUsername          = fs.String("twitter-username", "", "the twitter username you are connecting to")
-		mastodonClientKey        = fs.String("mastodon-client-key", "", "the mastodon client key")
-		mastodonClientSecret     = fs.String("mastodon-client-secret", "", "the mastodon client secret")
-		mastodonAccessToken      = fs.String("mastodon-access-token", "", "the mastodon access token")
-		mastodonServer           = fs.String("mastodon-server", "", "the mastodon instance you are using")
-		feedURL                  = fs.String("feed-url", "https://annoying.technology/index.xml", "the direct url to the feed index")
-		cacheDatabasePath        = fs.String("cache-database-path", "webhook-receiver.db", "the path to the cache database, to prevent duplicate notifications")
-		hookToken                = fs.String("hook-token", "changeme", "the secret token for the hook, to prevent other people from hitting the hook")
+		configFile             = fs.String("config", "", "the path to a config file with one flag per line, e.g. \"port 8080\"")
+		environment            = fs.String("environment", "develop", "the environment we are running in")
+		port                   = fs.String("port", "8080", "the port webhook-receiver is running on")
+		feedURL                = fs.String("feed-url", "https://annoying.technology/index.xml", "the direct url to the feed index")
+		feeds                  = fs.String("feeds", "", "comma separated names of additional feeds, configured with WR_<NAME>_FEED_URL, WR_<NAME>_HOOK_TOKEN and their own notifiers")
+		cacheDatabasePath      = fs.String("cache-database-path", "webhook-receiver.db", "the path to the cache database, to prevent duplicate notifications")
+		postingWindow          = fs.String("posting-window", "", "when posts are published, e.g. \"mon-fri 09:00-18:00\", posts outside of it are scheduled")
+		postingWindowTimezone  = fs.String("posting-window-timezone", "", "the time zone of the posting window, e.g. Europe/Berlin (default: the reporting time zone)")
+		timezone               = fs.String("timezone", "UTC", "the reporting time zone, it's where days start for cadences like \"posts=1;per=day\"")
+		cadence                = fs.String("cadence", schedule.DefaultCadence, "how often notifiers post, e.g. \"posts=3;per=24h;gap=2h;drip\" or \"immediate\"")
+		feedTimeout            = fs.Duration("feed-timeout", 30*time.Second, "the timeout of a single feed request")
+		feedUserAgent          = fs.String("feed-user-agent", feed.DefaultUserAgent, "the User-Agent of requests for the feed and linked pages")
+		feedRetries            = fs.Int("feed-retries", 3, "how often failed feed requests are retried")
+		pollInterval           = fs.Duration("poll-interval", 0, "how often to check the feed for new items without a web hook, e.g. 15m (default: only on web hooks)")
+		pollJitter             = fs.Duration("poll-jitter", time.Minute, "the maximum random delay added to every poll interval")
+		workers                = fs.Int("workers", 1, "the number of workers processing queued web hooks")
+		hookToken              = fs.String("hook-token", "changeme", "the secret token for the hook, to prevent other people from hitting the hook")
+		hookProviders          = fs.String("hook-providers", "gitlab,netlify", "comma separated list of enabled web hook providers (gitlab, github, netlify, vercel, cloudflare-pages, generic), or none to only poll the feeds")
+		gitlabFilter           = fs.String("gitlab-filter", "kind=pipeline;ref=main;status=success", "the filter for gitlab events that trigger a post")
+		githubFilter           = fs.String("github-filter", "kind=workflow_run,deployment_status;ref=main;status=success", "the filter for github events that trigger a post")
+		netlifyFilter          = fs.String("netlify-filter", "status=ready;environment=production", "the filter for netlify events that trigger a post")
+		vercelFilter           = fs.String("vercel-filter", "kind=deployment.succeeded,deployment-ready;environment=production", "the filter for vercel events that trigger a post")
+		cloudflarePagesFilter  = fs.String("cloudflare-pages-filter", "kind=pages_event_alert;status=EVENT_DEPLOYMENT_SUCCESS;environment=production", "the filter for cloudflare pages events that trigger a post")
+		genericFilter          = fs.String("generic-filter", "status=,success", "the filter for generic events that trigger a post")
+		githubSecret           = fs.String("github-secret", "", "the secret of the github web hook, if set requests have to be signed with X-Hub-Signature-256")
+		gitlabSecret           = fs.String("gitlab-secret", "", "the secret token of the gitlab web hook, if set requests have to contain it in X-Gitlab-Token")
+		netlifySecret          = fs.String("netlify-secret", "", "the JWS secret token of the netlify notification, if set requests have to be signed with X-Webhook-Signature")
+		genericSecret          = fs.String("generic-secret", "", "the secret for generic web hooks, if set requests have to contain a hex encoded HMAC-SHA256 of the body")
+		genericSignatureHeader = fs.String("generic-signature-header", "X-Signature-256", "the header containing the HMAC-SHA256 signature for generic web hooks")
+		twitterUsername        = fs.String("twitter-username", "", "deprecated: the twitter user is looked up with the credentials, it's only kept so existing configs still work")
 	)
+	defaultNotifiers := newNotifierFlags(fs, "twitter-token.json")
 
-	ff.Parse(fs, os.Args[1:],
+	parseErr := ff.Parse(fs, os.Args[1:],
 		ff.WithConfigFileFlag("config"),
-		ff.WithConfigFileParser(ff.PlainParser),
+		ff.WithConfigFileParser(mainConfigParser(fs)),
 		ff.WithEnvVarPrefix("WR"),
 	)
 
@@ -76,6 +98,14 @@ func main() {
 	}
 	l = log.With(l, "ts", log.DefaultTimestampUTC, "caller", log.DefaultCaller)
 
+	if parseErr != nil {
+		level.Error(l).Log("msg", "error parsing flags", "err", parseErr)
+		return
+	}
+	if *twitterUsername != "" {
+		level.Warn(l).Log("msg", "twitter-username is deprecated and ignored, the user is looked up with the credentials")
+	}
+
 	// Connect to sqlite database, create if it doesn't exist and run available migrations if needed
 	db, err := sqlx.Open("sqlite3", *cacheDatabasePath)
 	if err != nil {
@@ -101,65 +131,152 @@ func main() {
 		return
 	}
 
+	versionBefore, err := goose.GetDBVersion(db.DB)
+	if err != nil {
+		level.Error(l).Log("msg", "error getting database version", "err", err)
+		return
+	}
 	if err := goose.Up(db.DB, "migrations"); err != nil {
 		level.Error(l).Log("msg", "error running migrations", "err", err)
 		return
 	}
+	// Older databases only stored the day in the time zone of the server, the migration can't know which one that was
+	if versionBefore > 0 && versionBefore < dateTimestampsMigration {
+		level.Warn(l).Log("msg", "converted the dates in the cache to timestamps, assuming the days started at midnight UTC. Posts of the last day may count towards the wrong day of a cadence", "timezone", *timezone)
+	}
 
-	var notifiers notification.Notifiers
-	// Set up Twitter client
-	if *twitterConsumerKey != "" && *twitterAccessTokenSecret != "" && *twitterConsumerSecretKey != "" && *twitterAccessToken != "" {
-		config := oauth1.NewConfig(*twitterConsumerKey, *twitterConsumerSecretKey)
-		token := oauth1.NewToken(*twitterAccessToken, *twitterAccessTokenSecret)
-		httpClient := config.Client(oauth1.NoContext, token)
-		client := twitter.NewClient(httpClient)
+	// The feed of the main flags is the default feed, additional feeds have their own hook token and notifiers
+	feedFlagSets := []*feedFlags{{name: defaultFeed, url: feedURL, hookToken: hookToken, notifiers: defaultNotifiers}}
+	for _, name := range strings.Split(*feeds, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		feedFlag, err := parseFeedFlags(name, *configFile)
+		if err != nil {
+			level.Error(l).Log("msg", "error reading feed settings", "err", err)
+			return
+		}
+		feedFlagSets = append(feedFlagSets, feedFlag)
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		level.Error(l).Log("msg", "error loading time zone", "err", err)
+		return
+	}
+	var listenerFeeds []hooklistener.Feed
+	hookTokens := make(map[string]string)
+	for _, feedFlag := range feedFlagSets {
+		if other, ok := hookTokens[*feedFlag.hookToken]; ok {
+			level.Error(l).Log("err", "feeds can't share a hook token", "feed", feedFlag.name, "other_feed", other)
+			return
+		}
+		hookTokens[*feedFlag.hookToken] = feedFlag.name
 
-		// Get user information for setup testing
-		user, resp, err := client.Users.Show(&twitter.UserShowParams{
-			ScreenName: *twitterUsername,
-		})
+		fl := log.With(l, "feed", feedFlag.name)
+		notifiers, err := feedFlag.notifiers.notifiers(fl)
+		if err != nil {
+			level.Error(fl).Log("msg", "error setting up notifiers", "err", err)
+			return
+		}
+		cadences, err := feedFlag.notifiers.parseCadences(location)
 		if err != nil {
-			level.Error(l).Log("msg", "error getting user information from twitter", "err", err)
+			level.Error(fl).Log("msg", "error parsing cadence", "err", err)
 			return
 		}
-		if resp.StatusCode != http.StatusOK {
-			level.Error(l).Log("err", "status code not 200, check credentials and api.twitterstat.us")
+		// For local development we inject a mock notifier which just prints out a notification. That way we can test the caching
+		// logic without setting up real services. The name has to follow the naming convention "mock[\d+]" as defined in service.go
+		if *environment == "develop" {
+			notifiers = append(notifiers, notification.NewMockRepository(fl, "mock1"))
+			notifiers = append(notifiers, notification.NewMockRepository(fl, "mock2"))
+			notifiers = append(notifiers, notification.NewMockRepository(fl, "twitter"))
+			notifiers = append(notifiers, notification.NewMockRepository(fl, "mastodon"))
+		}
+
+		if len(notifiers) == 0 {
+			level.Error(fl).Log("err", "no notifiers are configured. make sure to set up at least one of twitter, mastodon, bluesky, slack, discord, matrix, telegram, email or a web hook")
 			return
+		} else {
+			level.Info(fl).Log("msg", "configured notifiers", "feed_url", *feedFlag.url, "notifiers", notifiers.String())
 		}
-		level.Info(l).Log("msg", "connected to twitter", "twitter_user_id", user.IDStr, "twitter_user", user.ScreenName, "http_status", resp.StatusCode)
-		notifiers = append(notifiers, notification.NewTwitterRepository(l, client, user))
-	}
-	// Setup Mastodon Client
-	if *mastodonServer != "" && *mastodonClientKey != "" && *mastodonClientSecret != "" && *mastodonAccessToken != "" {
-		cm := mastodon.NewClient(&mastodon.Config{
-			Server:       *mastodonServer,
-			ClientID:     *mastodonClientKey,
-			ClientSecret: *mastodonClientSecret,
-			AccessToken:  *mastodonAccessToken,
+		listenerFeeds = append(listenerFeeds, hooklistener.Feed{
+			Name:      feedFlag.name,
+			URL:       *feedFlag.url,
+			HookToken: *feedFlag.hookToken,
+			Notifiers: notifiers,
+			Cadences:  cadences,
 		})
-		clientMastodon, err := cm.GetAccountCurrentUser(context.Background())
+	}
+
+	// Every provider has a filter expression deciding which events trigger a post, e.g. "ref=main,release/*;status=success"
+	filters := map[string]string{
+		"gitlab":           *gitlabFilter,
+		"github":           *githubFilter,
+		"netlify":          *netlifyFilter,
+		"vercel":           *vercelFilter,
+		"cloudflare-pages": *cloudflarePagesFilter,
+		"generic":          *genericFilter,
+	}
+	// The legacy route only has the token in the path, a token that is also the name of a provider would be routed as
+	// that provider instead
+	for token, feed := range hookTokens {
+		if _, ok := filters[token]; ok {
+			level.Error(l).Log("err", "the hook token of a feed can't be the name of a web hook provider", "feed", feed, "provider", token)
+			return
+		}
+	}
+	var providers hooklistener.Providers
+	for _, name := range strings.Split(*hookProviders, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		expr, ok := filters[name]
+		if !ok {
+			level.Error(l).Log("err", "unknown web hook provider", "provider", name)
+			return
+		}
+		filter, err := hooklistener.ParseFilter(expr)
 		if err != nil {
-			level.Error(l).Log("err", "error getting user information from mastodon")
+			level.Error(l).Log("msg", "error parsing web hook filter", "provider", name, "err", err)
 			return
 		}
-		level.Info(l).Log("msg", "connected to mastodon", "mastodon_user_id", clientMastodon.ID, "mastodon_user", clientMastodon.Username)
-		notifiers = append(notifiers, notification.NewMastodonRepository(l, cm))
+		switch name {
+		case "gitlab":
+			providers = append(providers, hooklistener.NewGitlabProvider(filter))
+		case "github":
+			providers = append(providers, hooklistener.NewGithubProvider(filter))
+		case "netlify":
+			providers = append(providers, hooklistener.NewNetlifyProvider(filter))
+		case "vercel":
+			providers = append(providers, hooklistener.NewVercelProvider(filter))
+		case "cloudflare-pages":
+			providers = append(providers, hooklistener.NewCloudflarePagesProvider(filter))
+		case "generic":
+			providers = append(providers, hooklistener.NewGenericProvider(filter))
+		}
 	}
-
-	// For local development we inject a mock notifier which just prints out a notification. That way we can test the caching
-	// logic without setting up real services. The name has to follow the naming convention "mock[\d+]" as defined in service.go
-	if *environment == "develop" {
-		notifiers = append(notifiers, notification.NewMockRepository(l, "mock1"))
-		notifiers = append(notifiers, notification.NewMockRepository(l, "mock2"))
-		notifiers = append(notifiers, notification.NewMockRepository(l, "twitter"))
-		notifiers = append(notifiers, notification.NewMockRepository(l, "mastodon"))
+	// Without web hooks the feeds are only polled
+	if len(providers) == 0 && *pollInterval <= 0 {
+		level.Error(l).Log("err", "no web hook providers are enabled and polling is disabled, set hook-providers or poll-interval")
+		return
+	}
+	if len(providers) > 0 {
+		level.Info(l).Log("msg", "enabled web hook providers", "providers", providers.String())
 	}
 
-	if len(notifiers) == 0 {
-		level.Error(l).Log("err", "no notifiers are configured. make sure to set up twitter and/or mastodon")
-		return
-	} else {
-		level.Info(l).Log("msg", "configured notifiers", "notifiers", notifiers.String())
+	verifiers := make(map[string]hooklistener.Verifier)
+	if *githubSecret != "" {
+		verifiers["github"] = hooklistener.NewGithubVerifier(*githubSecret)
+	}
+	if *gitlabSecret != "" {
+		verifiers["gitlab"] = hooklistener.NewGitlabVerifier(*gitlabSecret)
+	}
+	if *netlifySecret != "" {
+		verifiers["netlify"] = hooklistener.NewNetlifyVerifier(*netlifySecret)
+	}
+	if *genericSecret != "" {
+		verifiers["generic"] = hooklistener.NewHMACVerifier(*genericSecret, *genericSignatureHeader)
 	}
 
 	// Set up HTTP API
@@ -168,15 +285,54 @@ func main() {
 		w.Write([]byte("webhook-receiver"))
 	})
 
-	fr := feed.NewRepository(l)
+	fr := feed.NewRepository(l, &http.Client{Timeout: *feedTimeout}, db, *feedUserAgent, *feedRetries)
 	cacheRepository, err := cache.NewRepository(l, db)
 	if err != nil {
-		fmt.Println("err", err)
+		level.Error(l).Log("msg", "error setting up cache repository", "err", err)
+		return
+	}
+	queueRepository, err := queue.NewRepository(l, db)
+	if err != nil {
+		level.Error(l).Log("msg", "error setting up queue repository", "err", err)
 		return
 	}
-	listenerService := hooklistener.NewService(l, fr, notifiers, cacheRepository, *feedURL, *hookToken)
+	var window *schedule.Window
+	if *postingWindow != "" {
+		windowLocation := location
+		if *postingWindowTimezone != "" {
+			if windowLocation, err = time.LoadLocation(*postingWindowTimezone); err != nil {
+				level.Error(l).Log("msg", "error loading posting window time zone", "err", err)
+				return
+			}
+		}
+		window, err = schedule.ParseWindow(*postingWindow, windowLocation)
+		if err != nil {
+			level.Error(l).Log("msg", "error parsing posting window", "err", err)
+			return
+		}
+		level.Info(l).Log("msg", "using posting window", "posting_window", *postingWindow, "timezone", windowLocation)
+	}
+	defaultCadence, err := schedule.ParseCadence(*cadence, location)
+	if err != nil {
+		level.Error(l).Log("msg", "error parsing cadence", "err", err)
+		return
+	}
+	listenerService := hooklistener.NewService(l, fr, listenerFeeds, cacheRepository, queueRepository, providers, verifiers, hooklistener.PostingOptions{
+		Window:         window,
+		DefaultCadence: defaultCadence,
+	})
+
+	if err := listenerService.Start(context.Background(), *workers); err != nil {
+		level.Error(l).Log("msg", "error starting workers", "err", err)
+		return
+	}
+	if *pollInterval > 0 {
+		listenerService.Poll(context.Background(), *pollInterval, *pollJitter)
+	}
 
-	r.Mount("/incoming-hooks", hooklistener.NewHandler(*listenerService))
+	if len(providers) > 0 {
+		r.Mount("/incoming-hooks", hooklistener.NewHandler(*listenerService))
+	}
 
 	level.Info(l).Log("msg", fmt.Sprintf("webhook-receiver is running on :%s", *port), "environment", *environment)
 
@@ -187,3 +343,70 @@ func main() {
 		return
 	}
 }
+
+// newFormatter sets up the post template of a notifier. Values starting with "@" are read from a file, an empty value
+// uses the default template.
+func newFormatter(name string, value string, length notification.Length) (*notification.Formatter, error) {
+	if value == "" {
+		value = notification.DefaultTemplate
+	}
+	text, err := readTemplate(value)
+	if err != nil {
+		return nil, err
+	}
+	return notification.NewFormatter(name, text, length)
+}
+
+// readTemplate returns the text of a template, values starting with "@" are read from a file
+func readTemplate(value string) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+	b, err := os.ReadFile(strings.TrimPrefix(value, "@"))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// parseHeaders parses a semicolon separated list of headers, e.g. "Authorization: Bearer changeme;X-Source: feed"
+func parseHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, header := range strings.Split(value, ";") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	}
+	return headers, nil
+}
+
+// parseMapping parses a semicolon separated list of categories and values, e.g. "nsfw=NSFW;spoilers=Spoilers". The
+// categories are lowercased, as they are matched case-insensitively.
+func parseMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected \"category=value\"", pair)
+		}
+		mapping[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(v)
+	}
+	return mapping, nil
+}
+
+// values returns the values of the mapping
+func values(mapping map[string]string) []string {
+	var v []string
+	for _, value := range mapping {
+		v = append(v, value)
+	}
+	return v
+}
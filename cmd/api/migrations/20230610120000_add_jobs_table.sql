@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE jobs
+(
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    kind       text     NOT NULL,
+    payload    text     NOT NULL,
+    state      text     NOT NULL,
+    attempts   integer  NOT NULL DEFAULT 0,
+    last_error text     NOT NULL DEFAULT '',
+    run_at     datetime NOT NULL,
+    created_at datetime NOT NULL,
+    updated_at datetime NOT NULL
+);
+
+CREATE INDEX jobs_state_run_at_index
+    ON jobs (state, run_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX jobs_state_run_at_index;
+DROP TABLE jobs;
+-- +goose StatementEnd
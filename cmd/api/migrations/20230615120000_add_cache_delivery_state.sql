@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Everything that's already in the cache was posted before we tracked delivery states
+ALTER TABLE cache ADD COLUMN state text NOT NULL DEFAULT 'sent';
+ALTER TABLE cache ADD COLUMN attempts integer NOT NULL DEFAULT 1;
+ALTER TABLE cache ADD COLUMN last_error text NOT NULL DEFAULT '';
+ALTER TABLE cache ADD COLUMN updated_at datetime NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE cache DROP COLUMN updated_at;
+ALTER TABLE cache DROP COLUMN last_error;
+ALTER TABLE cache DROP COLUMN attempts;
+ALTER TABLE cache DROP COLUMN state;
+-- +goose StatementEnd
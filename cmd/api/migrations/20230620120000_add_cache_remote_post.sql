@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE cache ADD COLUMN remote_id text NOT NULL DEFAULT '';
+ALTER TABLE cache ADD COLUMN remote_url text NOT NULL DEFAULT '';
+ALTER TABLE cache ADD COLUMN published_at datetime NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE cache DROP COLUMN published_at;
+ALTER TABLE cache DROP COLUMN remote_url;
+ALTER TABLE cache DROP COLUMN remote_id;
+-- +goose StatementEnd
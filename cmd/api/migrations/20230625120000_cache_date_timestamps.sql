@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Dates used to be only the day in the local time zone of the server, which we don't know anymore. They are converted
+-- to the start of the day in UTC, and used as the publish time of entries from before we stored it.
+CREATE TABLE cache_timestamps
+(
+    key                  text     NOT NULL,
+    notification_service text     NOT NULL,
+    date                 datetime NOT NULL,
+    state                text     NOT NULL DEFAULT 'sent',
+    attempts             integer  NOT NULL DEFAULT 1,
+    last_error           text     NOT NULL DEFAULT '',
+    updated_at           datetime NOT NULL DEFAULT '',
+    remote_id            text     NOT NULL DEFAULT '',
+    remote_url           text     NOT NULL DEFAULT '',
+    published_at         datetime NOT NULL DEFAULT ''
+);
+
+INSERT INTO cache_timestamps (key, notification_service, date, state, attempts, last_error, updated_at, remote_id, remote_url, published_at)
+SELECT key,
+       notification_service,
+       CASE WHEN length(date) = 10 THEN date || 'T00:00:00Z' ELSE date END,
+       state,
+       attempts,
+       last_error,
+       CASE WHEN updated_at != '' THEN updated_at WHEN length(date) = 10 THEN date || 'T00:00:00Z' ELSE date END,
+       remote_id,
+       remote_url,
+       CASE WHEN published_at != '' THEN published_at WHEN length(date) = 10 THEN date || 'T00:00:00Z' ELSE date END
+FROM cache;
+
+DROP INDEX cache_key_notification_service_uindex;
+DROP TABLE cache;
+ALTER TABLE cache_timestamps RENAME TO cache;
+
+CREATE UNIQUE INDEX cache_key_notification_service_uindex
+    ON cache (key, notification_service);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE cache_days
+(
+    key                  text     NOT NULL,
+    notification_service text     NOT NULL,
+    date                 text     NOT NULL,
+    state                text     NOT NULL DEFAULT 'sent',
+    attempts             integer  NOT NULL DEFAULT 1,
+    last_error           text     NOT NULL DEFAULT '',
+    updated_at           datetime NOT NULL DEFAULT '',
+    remote_id            text     NOT NULL DEFAULT '',
+    remote_url           text     NOT NULL DEFAULT '',
+    published_at         datetime NOT NULL DEFAULT ''
+);
+
+INSERT INTO cache_days (key, notification_service, date, state, attempts, last_error, updated_at, remote_id, remote_url, published_at)
+SELECT key, notification_service, substr(date, 1, 10), state, attempts, last_error, updated_at, remote_id, remote_url, published_at
+FROM cache;
+
+DROP INDEX cache_key_notification_service_uindex;
+DROP TABLE cache;
+ALTER TABLE cache_days RENAME TO cache;
+
+CREATE UNIQUE INDEX cache_key_notification_service_uindex
+    ON cache (key, notification_service);
+-- +goose StatementEnd
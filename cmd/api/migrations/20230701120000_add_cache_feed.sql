@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Everything that's already in the cache was posted from the only feed we supported before, which is now the default
+ALTER TABLE cache ADD COLUMN feed text NOT NULL DEFAULT 'default';
+
+DROP INDEX cache_key_notification_service_uindex;
+CREATE UNIQUE INDEX cache_feed_key_notification_service_uindex
+    ON cache (feed, key, notification_service);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM cache WHERE feed != 'default';
+
+DROP INDEX cache_feed_key_notification_service_uindex;
+CREATE UNIQUE INDEX cache_key_notification_service_uindex
+    ON cache (key, notification_service);
+
+ALTER TABLE cache DROP COLUMN feed;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The last response of every feed, so requests after a restart are conditional too. Databases that ran this migration
+-- under its earlier version, before the cache feed migration, already have the table.
+CREATE TABLE IF NOT EXISTS feed_cache
+(
+    url           text     PRIMARY KEY,
+    etag          text     NOT NULL DEFAULT '',
+    last_modified text     NOT NULL DEFAULT '',
+    body          blob     NOT NULL,
+    updated_at    datetime NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE feed_cache;
+-- +goose StatementEnd
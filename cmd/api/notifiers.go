@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dewey/webhook-receiver/notification"
+	"github.com/dewey/webhook-receiver/schedule"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/mattn/go-mastodon"
+	"github.com/pkg/errors"
+)
+
+// notifierFlags are the settings of the notifiers of a feed. The default feed reads them from the main flags, every
+// other feed has its own flag set, e.g. WR_PODCAST_MASTODON_SERVER for the feed "podcast".
+type notifierFlags struct {
+	twitterConsumerKey       *string
+	twitterConsumerSecretKey *string
+	twitterAccessToken       *string
+	twitterAccessTokenSecret *string
+	twitterClientID          *string
+	twitterClientSecret      *string
+	twitterRefreshToken      *string
+	twitterTokenFile         *string
+	twitterAPIURL            *string
+	twitterTemplate          *string
+	mastodonClientKey        *string
+	mastodonClientSecret     *string
+	mastodonAccessToken      *string
+	mastodonServer           *string
+	mastodonMaxCharacters    *int
+	mastodonTemplate         *string
+	mastodonVisibility       *string
+	mastodonSpoilerText      *string
+	mastodonSensitive        *bool
+	mastodonLanguage         *string
+	mastodonContentWarnings  *string
+	mastodonVisibilities     *string
+	blueskyPDSURL            *string
+	blueskyIdentifier        *string
+	blueskyAppPassword       *string
+	blueskyTemplate          *string
+	slackWebhookURL          *string
+	slackTemplate            *string
+	discordWebhookURL        *string
+	discordTemplate          *string
+	matrixHomeserverURL      *string
+	matrixAccessToken        *string
+	matrixRoomID             *string
+	matrixTemplate           *string
+	telegramBotToken         *string
+	telegramChatID           *string
+	telegramTemplate         *string
+	telegramLinkPreview      *bool
+	telegramAPIURL           *string
+	emailSMTPHost            *string
+	emailSMTPPort            *int
+	emailSMTPUsername        *string
+	emailSMTPPassword        *string
+	emailSMTPSecurity        *string
+	emailFrom                *string
+	emailTo                  *string
+	emailSubjectTemplate     *string
+	emailTextTemplate        *string
+	emailHTMLTemplate        *string
+	webhookURL               *string
+	webhookHeaders           *string
+	webhookSecret            *string
+	webhookSignatureHeader   *string
+	webhookTemplate          *string
+	webhookTimeout           *time.Duration
+	webhookRetries           *int
+	// cadences are the cadences of the notifiers, keyed by notifier name
+	cadences map[string]*string
+}
+
+// newNotifierFlags defines the notifier flags on the flag set. Feeds need their own Twitter token file, the refreshed
+// tokens of different accounts can't be stored in the same one.
+func newNotifierFlags(fs *flag.FlagSet, tokenFile string) *notifierFlags {
+	return &notifierFlags{
+		twitterConsumerKey:       fs.String("twitter-consumer-key", "", "the twitter consumer key"),
+		twitterConsumerSecretKey: fs.String("twitter-consumer-secret-key", "", "the twitter consumer secret key"),
+		twitterAccessToken:       fs.String("twitter-access-token", "", "the twitter access token"),
+		twitterAccessTokenSecret: fs.String("twitter-access-token-secret", "", "the twitter access token secret"),
+		twitterClientID:          fs.String("twitter-client-id", "", "the oauth 2.0 client id, if using a user token instead of the access token"),
+		twitterClientSecret:      fs.String("twitter-client-secret", "", "the oauth 2.0 client secret, only for confidential clients"),
+		twitterRefreshToken:      fs.String("twitter-refresh-token", "", "the oauth 2.0 refresh token to get the first access token with, afterwards it's read from the token file"),
+		twitterTokenFile:         fs.String("twitter-token-file", tokenFile, "the path to the file the refreshed oauth 2.0 tokens are stored in"),
+		twitterAPIURL:            fs.String("twitter-api-url", notification.TwitterAPIURL, "the base url of the twitter api"),
+		twitterTemplate:          fs.String("twitter-template", "", "the text/template for tweets, prefix with @ to read it from a file"),
+		mastodonClientKey:        fs.String("mastodon-client-key", "", "the mastodon client key"),
+		mastodonClientSecret:     fs.String("mastodon-client-secret", "", "the mastodon client secret"),
+		mastodonAccessToken:      fs.String("mastodon-access-token", "", "the mastodon access token"),
+		mastodonServer:           fs.String("mastodon-server", "", "the mastodon instance you are using"),
+		mastodonMaxCharacters:    fs.Int("mastodon-max-characters", 0, "the maximum length of a toot, fetched from the instance if not set"),
+		mastodonTemplate:         fs.String("mastodon-template", "", "the text/template for toots, prefix with @ to read it from a file"),
+		mastodonVisibility:       fs.String("mastodon-visibility", "", "the visibility of toots (public, unlisted, private, direct), uses the default of the account if not set"),
+		mastodonSpoilerText:      fs.String("mastodon-spoiler-text", "", "the content warning of every toot"),
+		mastodonSensitive:        fs.Bool("mastodon-sensitive", false, "mark media of every toot as sensitive"),
+		mastodonLanguage:         fs.String("mastodon-language", "", "the language of toots, taken from the feed if not set"),
+		mastodonContentWarnings:  fs.String("mastodon-content-warnings", "", "content warnings for items with a category, e.g. \"nsfw=NSFW;spoilers=Spoilers\""),
+		mastodonVisibilities:     fs.String("mastodon-category-visibility", "", "visibilities for items with a category, e.g. \"announcement=unlisted\""),
+		blueskyPDSURL:            fs.String("bluesky-pds-url", notification.BlueskyPDSURL, "the url of the personal data server of the bluesky account"),
+		blueskyIdentifier:        fs.String("bluesky-identifier", "", "the handle or email of the bluesky account"),
+		blueskyAppPassword:       fs.String("bluesky-app-password", "", "an app password of the bluesky account"),
+		blueskyTemplate:          fs.String("bluesky-template", "", "the text/template for bluesky posts, prefix with @ to read it from a file"),
+		slackWebhookURL:          fs.String("slack-webhook-url", "", "the url of the slack incoming webhook"),
+		slackTemplate:            fs.String("slack-template", notification.MessageTemplate, "the text/template for the text of slack messages, prefix with @ to read it from a file"),
+		discordWebhookURL:        fs.String("discord-webhook-url", "", "the url of the discord channel webhook"),
+		discordTemplate:          fs.String("discord-template", notification.MessageTemplate, "the text/template for the description of discord embeds, prefix with @ to read it from a file"),
+		matrixHomeserverURL:      fs.String("matrix-homeserver-url", "", "the url of the matrix homeserver, e.g. https://matrix-client.matrix.org"),
+		matrixAccessToken:        fs.String("matrix-access-token", "", "the access token of the matrix user"),
+		matrixRoomID:             fs.String("matrix-room-id", "", "the id of the room the matrix user posts to, e.g. !abc:matrix.org"),
+		matrixTemplate:           fs.String("matrix-template", "", "the text/template for matrix messages, prefix with @ to read it from a file"),
+		telegramBotToken:         fs.String("telegram-bot-token", "", "the token of the telegram bot"),
+		telegramChatID:           fs.String("telegram-chat-id", "", "the chat the telegram bot posts to, numeric or @channelusername"),
+		telegramTemplate:         fs.String("telegram-template", notification.TelegramTemplate, "the text/template for telegram messages in html, prefix with @ to read it from a file"),
+		telegramLinkPreview:      fs.Bool("telegram-link-preview", true, "show a preview of the link in telegram messages"),
+		telegramAPIURL:           fs.String("telegram-api-url", notification.TelegramAPIURL, "the base url of the telegram bot api"),
+		emailSMTPHost:            fs.String("email-smtp-host", "", "the host of the smtp server emails are sent through"),
+		emailSMTPPort:            fs.Int("email-smtp-port", 587, "the port of the smtp server"),
+		emailSMTPUsername:        fs.String("email-smtp-username", "", "the username for smtp authentication, leave empty to send without authentication"),
+		emailSMTPPassword:        fs.String("email-smtp-password", "", "the password for smtp authentication"),
+		emailSMTPSecurity:        fs.String("email-smtp-security", notification.SMTPStartTLS, "how the smtp connection is secured (starttls, tls, none)"),
+		emailFrom:                fs.String("email-from", "", "the sender of emails, e.g. \"Blog <posts@example.com>\""),
+		emailTo:                  fs.String("email-to", "", "comma separated list of recipients, or the address of a mailing list"),
+		emailSubjectTemplate:     fs.String("email-subject-template", notification.EmailSubjectTemplate, "the text/template for the subject of emails, prefix with @ to read it from a file"),
+		emailTextTemplate:        fs.String("email-text-template", notification.EmailTextTemplate, "the text/template for the plain text body of emails, prefix with @ to read it from a file"),
+		emailHTMLTemplate:        fs.String("email-html-template", notification.EmailHTMLTemplate, "the html/template for the html body of emails, prefix with @ to read it from a file"),
+		webhookURL:               fs.String("webhook-url", "", "the url new feed items are posted to as json"),
+		webhookHeaders:           fs.String("webhook-headers", "", "semicolon separated list of extra headers for the web hook, e.g. \"Authorization: Bearer changeme\""),
+		webhookSecret:            fs.String("webhook-secret", "", "the secret to sign the body of the web hook with, the signature header is set to \"sha256=\" followed by the hex encoded HMAC-SHA256 of the body"),
+		webhookSignatureHeader:   fs.String("webhook-signature-header", "X-Signature-256", "the header the signature of the web hook is sent in, the value looks like \"sha256=<hex>\""),
+		webhookTemplate:          fs.String("webhook-template", "", "the text/template for the body of the web hook instead of json, prefix with @ to read it from a file"),
+		webhookTimeout:           fs.Duration("webhook-timeout", 10*time.Second, "the timeout of a single web hook request"),
+		webhookRetries:           fs.Int("webhook-retries", 3, "how often failed web hook requests are retried"),
+		cadences: map[string]*string{
+			"twitter":  fs.String("twitter-cadence", "", "the cadence of twitter, if it's different from the default"),
+			"mastodon": fs.String("mastodon-cadence", "", "the cadence of mastodon, if it's different from the default"),
+			"bluesky":  fs.String("bluesky-cadence", "", "the cadence of bluesky, if it's different from the default"),
+			"slack":    fs.String("slack-cadence", "", "the cadence of slack, if it's different from the default"),
+			"discord":  fs.String("discord-cadence", "", "the cadence of discord, if it's different from the default"),
+			"matrix":   fs.String("matrix-cadence", "", "the cadence of matrix, if it's different from the default"),
+			"telegram": fs.String("telegram-cadence", "", "the cadence of telegram, if it's different from the default"),
+			"email":    fs.String("email-cadence", "", "the cadence of email, if it's different from the default"),
+			"webhook":  fs.String("webhook-cadence", "", "the cadence of the outbound web hook, if it's different from the default"),
+		},
+	}
+}
+
+// parseCadences parses the cadences of the notifiers, notifiers without one aren't in the map
+func (f *notifierFlags) parseCadences(loc *time.Location) (map[string]*schedule.Cadence, error) {
+	cadences := make(map[string]*schedule.Cadence)
+	for name, expr := range f.cadences {
+		if *expr == "" {
+			continue
+		}
+		cadence, err := schedule.ParseCadence(*expr, loc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing cadence of %s", name)
+		}
+		cadences[name] = cadence
+	}
+	return cadences, nil
+}
+
+// notifiers sets up the configured notifiers. Notifiers with accounts connect to their service to check the
+// credentials.
+func (f *notifierFlags) notifiers(l log.Logger) (notification.Notifiers, error) {
+	var (
+		notifiers notification.Notifiers
+		err       error
+	)
+	// Set up Twitter client, either with OAuth 1.0a keys and tokens of the app or with an OAuth 2.0 user token
+	var twitterClient *http.Client
+	if *f.twitterConsumerKey != "" && *f.twitterAccessTokenSecret != "" && *f.twitterConsumerSecretKey != "" && *f.twitterAccessToken != "" {
+		twitterClient = notification.NewTwitterOAuth1Client(*f.twitterConsumerKey, *f.twitterConsumerSecretKey, *f.twitterAccessToken, *f.twitterAccessTokenSecret)
+	} else if *f.twitterClientID != "" {
+		twitterClient, err = notification.NewTwitterOAuth2Client(*f.twitterAPIURL, *f.twitterClientID, *f.twitterClientSecret, *f.twitterTokenFile, *f.twitterRefreshToken)
+		if err != nil {
+			return nil, errors.Wrap(err, "setting up twitter oauth 2.0 client")
+		}
+	}
+	if twitterClient != nil {
+		// Get user information for setup testing
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		user, err := notification.FetchTwitterUser(ctx, twitterClient, *f.twitterAPIURL)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting user information from twitter, check credentials and api.twitterstat.us")
+		}
+		level.Info(l).Log("msg", "connected to twitter", "twitter_user_id", user.ID, "twitter_user", user.Username)
+		formatter, err := newFormatter("twitter", *f.twitterTemplate, notification.TwitterLength())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading twitter template")
+		}
+		notifiers = append(notifiers, notification.NewTwitterRepository(l, twitterClient, *f.twitterAPIURL, user, formatter))
+	}
+	// Setup Mastodon Client
+	if *f.mastodonServer != "" && *f.mastodonClientKey != "" && *f.mastodonClientSecret != "" && *f.mastodonAccessToken != "" {
+		cm := mastodon.NewClient(&mastodon.Config{
+			Server:       *f.mastodonServer,
+			ClientID:     *f.mastodonClientKey,
+			ClientSecret: *f.mastodonClientSecret,
+			AccessToken:  *f.mastodonAccessToken,
+		})
+		clientMastodon, err := cm.GetAccountCurrentUser(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "getting user information from mastodon")
+		}
+		level.Info(l).Log("msg", "connected to mastodon", "mastodon_user_id", clientMastodon.ID, "mastodon_user", clientMastodon.Username)
+		// The length limit of posts depends on the instance, we only ask the instance if it's not configured
+		maxCharacters, urlLength := *f.mastodonMaxCharacters, 0
+		if maxCharacters == 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			maxCharacters, urlLength, err = notification.FetchMastodonLimits(ctx, http.DefaultClient, *f.mastodonServer)
+			cancel()
+			if err != nil {
+				return nil, errors.Wrap(err, "getting limits from mastodon instance")
+			}
+		}
+		level.Info(l).Log("msg", "using mastodon post limit", "max_characters", maxCharacters)
+		formatter, err := newFormatter("mastodon", *f.mastodonTemplate, notification.MastodonLength(maxCharacters, urlLength))
+		if err != nil {
+			return nil, errors.Wrap(err, "loading mastodon template")
+		}
+		contentWarnings, err := parseMapping(*f.mastodonContentWarnings)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing mastodon content warnings")
+		}
+		categoryVisibilities, err := parseMapping(*f.mastodonVisibilities)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing mastodon category visibility")
+		}
+		for _, visibility := range append([]string{*f.mastodonVisibility}, values(categoryVisibilities)...) {
+			switch visibility {
+			case "", notification.MastodonPublic, notification.MastodonUnlisted, notification.MastodonPrivate, notification.MastodonDirect:
+			default:
+				return nil, errors.Errorf("unknown mastodon visibility %q, use public, unlisted, private or direct", visibility)
+			}
+		}
+		notifiers = append(notifiers, notification.NewMastodonRepository(l, cm, formatter, notification.MastodonOptions{
+			Visibility:              *f.mastodonVisibility,
+			SpoilerText:             *f.mastodonSpoilerText,
+			Sensitive:               *f.mastodonSensitive,
+			Language:                *f.mastodonLanguage,
+			CategoryContentWarnings: contentWarnings,
+			CategoryVisibilities:    categoryVisibilities,
+		}))
+	}
+
+	// Set up Bluesky client
+	if *f.blueskyIdentifier != "" && *f.blueskyAppPassword != "" {
+		formatter, err := newFormatter("bluesky", *f.blueskyTemplate, notification.BlueskyLength())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading bluesky template")
+		}
+		br := notification.NewBlueskyRepository(l, &http.Client{Timeout: 30 * time.Second}, *f.blueskyPDSURL, *f.blueskyIdentifier, *f.blueskyAppPassword, formatter)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		session, err := br.CreateSession(ctx)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, "logging in to bluesky")
+		}
+		level.Info(l).Log("msg", "connected to bluesky", "bluesky_did", session.DID, "bluesky_handle", session.Handle)
+		notifiers = append(notifiers, br)
+	}
+
+	// Set up Slack and Discord, they only need the webhook urls
+	if *f.slackWebhookURL != "" {
+		formatter, err := newFormatter("slack", *f.slackTemplate, notification.SlackLength())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading slack template")
+		}
+		notifiers = append(notifiers, notification.NewSlackRepository(l, &http.Client{Timeout: 30 * time.Second}, *f.slackWebhookURL, formatter))
+	}
+	if *f.discordWebhookURL != "" {
+		formatter, err := newFormatter("discord", *f.discordTemplate, notification.DiscordLength())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading discord template")
+		}
+		notifiers = append(notifiers, notification.NewDiscordRepository(l, &http.Client{Timeout: 30 * time.Second}, *f.discordWebhookURL, formatter))
+	}
+
+	// Set up Matrix client
+	if *f.matrixHomeserverURL != "" && *f.matrixAccessToken != "" && *f.matrixRoomID != "" {
+		formatter, err := newFormatter("matrix", *f.matrixTemplate, notification.Length{})
+		if err != nil {
+			return nil, errors.Wrap(err, "loading matrix template")
+		}
+		mr := notification.NewMatrixRepository(l, &http.Client{Timeout: 30 * time.Second}, *f.matrixHomeserverURL, *f.matrixAccessToken, *f.matrixRoomID, formatter)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		userID, err := mr.Whoami(ctx)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting user information from matrix")
+		}
+		level.Info(l).Log("msg", "connected to matrix", "matrix_user_id", userID, "matrix_room_id", *f.matrixRoomID)
+		notifiers = append(notifiers, mr)
+	}
+
+	// Set up Telegram bot
+	if *f.telegramBotToken != "" && *f.telegramChatID != "" {
+		formatter, err := newFormatter("telegram", *f.telegramTemplate, notification.TelegramLength())
+		if err != nil {
+			return nil, errors.Wrap(err, "loading telegram template")
+		}
+		tr := notification.NewTelegramRepository(l, &http.Client{Timeout: 30 * time.Second}, *f.telegramAPIURL, *f.telegramBotToken, *f.telegramChatID, formatter, !*f.telegramLinkPreview)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		bot, err := tr.GetMe(ctx)
+		cancel()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting bot information from telegram")
+		}
+		level.Info(l).Log("msg", "connected to telegram", "telegram_bot", bot.Username, "telegram_chat_id", *f.telegramChatID)
+		notifiers = append(notifiers, tr)
+	}
+
+	// Set up email
+	if *f.emailSMTPHost != "" && *f.emailFrom != "" && *f.emailTo != "" {
+		switch *f.emailSMTPSecurity {
+		case notification.SMTPStartTLS, notification.SMTPTLS, notification.SMTPNone:
+		default:
+			return nil, errors.Errorf("unknown smtp security %q, use starttls, tls or none", *f.emailSMTPSecurity)
+		}
+		var recipients []string
+		for _, to := range strings.Split(*f.emailTo, ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				recipients = append(recipients, to)
+			}
+		}
+		subject, err := newFormatter("email-subject", *f.emailSubjectTemplate, notification.Length{})
+		if err != nil {
+			return nil, errors.Wrap(err, "loading email subject template")
+		}
+		text, err := newFormatter("email-text", *f.emailTextTemplate, notification.Length{})
+		if err != nil {
+			return nil, errors.Wrap(err, "loading email text template")
+		}
+		htmlText, err := readTemplate(*f.emailHTMLTemplate)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading email html template")
+		}
+		html, err := notification.NewHTMLTemplate("email-html", htmlText)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading email html template")
+		}
+		notifiers = append(notifiers, notification.NewEmailRepository(l, notification.SMTPConfig{
+			Host:     *f.emailSMTPHost,
+			Port:     *f.emailSMTPPort,
+			Username: *f.emailSMTPUsername,
+			Password: *f.emailSMTPPassword,
+			Security: *f.emailSMTPSecurity,
+		}, *f.emailFrom, recipients, subject, text, html))
+	}
+
+	// Set up outbound web hook
+	if *f.webhookURL != "" {
+		headers, err := parseHeaders(*f.webhookHeaders)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing web hook headers")
+		}
+		// Without a template we send the item as a json document
+		var formatter *notification.Formatter
+		if *f.webhookTemplate != "" {
+			formatter, err = newFormatter("webhook", *f.webhookTemplate, notification.Length{})
+			if err != nil {
+				return nil, errors.Wrap(err, "loading web hook template")
+			}
+		}
+		notifiers = append(notifiers, notification.NewWebhookRepository(l, &http.Client{Timeout: *f.webhookTimeout}, *f.webhookURL, headers, *f.webhookSecret, *f.webhookSignatureHeader, formatter, *f.webhookRetries))
+	}
+
+	return notifiers, nil
+}
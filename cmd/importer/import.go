@@ -9,6 +9,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"os"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -33,16 +34,28 @@ func main() {
 	}
 	defer f.Close()
 
-	// Import all legacy cache keys into the new system
+	// Import all legacy cache keys into the new system. Lines look like "<date>:<guid>", the GUID can contain colons
+	// itself. Dates are days, like in the migrations they become the start of the day in UTC.
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		if scanner.Text() != "" {
-			parts := strings.Split(scanner.Text(), ":")
-			_, err := db.NamedExec(`INSERT INTO cache (key, notification_service, date) VALUES (:key,:notification_service,:date)`,
+			parts := strings.SplitN(scanner.Text(), ":", 2)
+			if len(parts) != 2 {
+				level.Error(l).Log("msg", "invalid row, skipping", "row", scanner.Text())
+				continue
+			}
+			date, err := time.Parse("2006-01-02", parts[0])
+			if err != nil {
+				level.Error(l).Log("msg", "invalid date, skipping", "row", scanner.Text(), "err", err)
+				continue
+			}
+			_, err = db.NamedExec(`INSERT INTO cache (feed, key, notification_service, date, state, attempts, updated_at, published_at)
+				VALUES (:feed, :key, :notification_service, :date, 'sent', 1, :date, :date)`,
 				map[string]interface{}{
+					"feed":                 "default",
 					"key":                  parts[1],
 					"notification_service": "twitter",
-					"date":                 parts[0],
+					"date":                 date.UTC().Format(time.RFC3339),
 				})
 			if err != nil {
 				level.Error(l).Log("msg", "error inserting row into db", "row", parts[0], "err", err)
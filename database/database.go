@@ -0,0 +1,8 @@
+package database
+
+import "time"
+
+// FormatTime formats timestamps as RFC 3339 in UTC, so they can be compared as strings in queries
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
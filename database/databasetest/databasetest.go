@@ -0,0 +1,50 @@
+package databasetest
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pressly/goose/v3"
+)
+
+// NewDB returns a new database in the temporary directory of the test, migrated with the migrations of the api. It's
+// closed when the test is done.
+func NewDB(t testing.TB) *sqlx.DB {
+	t.Helper()
+	return NewDBAt(t, goose.MaxVersion)
+}
+
+// NewDBAt returns a new database like NewDB, but only migrated up to the given version. It's used to test migrations
+// with data from older versions.
+func NewDBAt(t testing.TB, version int64) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	MigrateTo(t, db, version)
+	return db
+}
+
+// MigrateTo runs the migrations of the api on the database up to the given version
+func MigrateTo(t testing.TB, db *sqlx.DB, version int64) {
+	t.Helper()
+	goose.SetLogger(goose.NopLogger())
+	if err := goose.SetDialect("sqlite"); err != nil {
+		t.Fatal(err)
+	}
+	if err := goose.UpTo(db.DB, migrationsDir(), version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// migrationsDir returns the migrations directory of the api, relative to this file so it doesn't depend on the
+// package the tests run in
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "cmd", "api", "migrations")
+}
@@ -1,10 +1,19 @@
 package feed
 
 import (
+	"context"
+
 	"github.com/mmcdole/gofeed"
 )
 
 // Repository is an interface for a RSS Feed fetcher
 type Repository interface {
-	Entries(feedURL string) ([]*gofeed.Item, error)
+	Feed(ctx context.Context, feedURL string) (*gofeed.Feed, error)
+	PageImage(ctx context.Context, pageURL string) (*Image, error)
+}
+
+// Image is the preview image of a page, as set in the og:image meta tag
+type Image struct {
+	URL string
+	Alt string
 }
@@ -1,26 +1,246 @@
 package feed
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dewey/webhook-receiver/database"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/jmoiron/sqlx"
 	"github.com/mmcdole/gofeed"
+	"github.com/pkg/errors"
+	"golang.org/x/net/html"
 )
 
+// maxPageSize is how much of a page we read looking for meta tags, they are in the head
+const maxPageSize = 1 << 20
+
+// maxFeedSize is the largest feed we accept, the whole feed is stored for conditional requests
+const maxFeedSize = 32 << 20
+
+// DefaultUserAgent is the User-Agent of requests for feeds and pages
+const DefaultUserAgent = "webhook-receiver (+https://github.com/dewey/webhook-receiver)"
+
+// feedRetryBackoff is the delay before the first retry of a failed feed request, it doubles with every retry
+const feedRetryBackoff = time.Second
+
 type repository struct {
-	l log.Logger
+	l         log.Logger
+	c         *http.Client
+	db        *sqlx.DB
+	userAgent string
+	retries   int
+	backoff   time.Duration
+}
+
+// cachedFeed is the last response of a feed, the body and the validators to check if it changed
+type cachedFeed struct {
+	URL          string `db:"url"`
+	ETag         string `db:"etag"`
+	LastModified string `db:"last_modified"`
+	Body         []byte `db:"body"`
 }
 
-// NewRepository initializes a new fetcher service
-func NewRepository(l log.Logger) *repository {
+// NewRepository initializes a new fetcher service. The last response of every feed is stored in the database, so
+// feeds are requested conditionally after a restart too. Failed feed requests are retried up to retries times if the
+// error is likely to go away, like a timeout or a server error.
+func NewRepository(l log.Logger, c *http.Client, db *sqlx.DB, userAgent string, retries int) *repository {
 	return &repository{
-		l: l,
+		l:         l,
+		c:         c,
+		db:        db,
+		userAgent: userAgent,
+		retries:   retries,
+		backoff:   feedRetryBackoff,
 	}
 }
 
-func (s *repository) Entries(feedURL string) ([]*gofeed.Item, error) {
-	fp := gofeed.NewParser()
-	feed, err := fp.ParseURL(feedURL)
+// Feed fetches and parses a feed. Feeds are requested with the ETag and Last-Modified of the last response, if the
+// feed didn't change since then the server doesn't have to send it again and the stored version is used.
+func (s *repository) Feed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	cached, err := s.cached(feedURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading cached feed")
+	}
+
+	var (
+		feed    *cachedFeed
+		backoff = s.backoff
+	)
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		feed, retry, err = s.fetch(ctx, feedURL, cached)
+		if err == nil || !retry || attempt >= s.retries {
+			break
+		}
+		level.Warn(s.l).Log("msg", "feed request failed, retrying", "feed_url", feedURL, "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching feed")
+	}
+	parsed, err := gofeed.NewParser().Parse(bytes.NewReader(feed.Body))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing feed")
+	}
+	if feed == cached {
+		level.Debug(s.l).Log("msg", "feed not modified, using cached feed", "feed_url", feedURL)
+		return parsed, nil
+	}
+	if err := s.store(feed); err != nil {
+		return nil, errors.Wrap(err, "storing feed")
+	}
+	return parsed, nil
+}
+
+// cached returns the last response of the feed, or nil if it was never fetched
+func (s *repository) cached(feedURL string) (*cachedFeed, error) {
+	var feed cachedFeed
+	err := s.db.Get(&feed, "SELECT url, etag, last_modified, body FROM feed_cache WHERE url=$1", feedURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	return feed.Items, nil
+	return &feed, nil
+}
+
+// store replaces the last response of the feed
+func (s *repository) store(feed *cachedFeed) error {
+	_, err := s.db.Exec(`INSERT INTO feed_cache (url, etag, last_modified, body, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (url) DO UPDATE SET etag=excluded.etag, last_modified=excluded.last_modified, body=excluded.body, updated_at=excluded.updated_at`,
+		feed.URL, feed.ETag, feed.LastModified, feed.Body, database.FormatTime(time.Now()))
+	return err
+}
+
+// fetch requests the feed, conditionally if there's a cached version which is returned if it's not modified. It
+// returns if the request should be retried on errors.
+func (s *repository) fetch(ctx context.Context, feedURL string, cached *cachedFeed) (*cachedFeed, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		// Timeouts and connection errors are worth another try, unless we gave up ourselves
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return cached, false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, true, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, true, err
+	}
+	if len(body) > maxFeedSize {
+		return nil, false, errors.Errorf("feed is larger than %d bytes", maxFeedSize)
+	}
+	return &cachedFeed{
+		URL:          feedURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Body:         body,
+	}, false, nil
+}
+
+// PageImage returns the og:image of a page, or nil if it doesn't have one
+func (s *repository) PageImage(ctx context.Context, pageURL string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", s.userAgent)
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d fetching page", resp.StatusCode)
+	}
+
+	var image Image
+	z := html.NewTokenizer(io.LimitReader(resp.Body, maxPageSize))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		t := z.Token()
+		// Nothing interesting comes after the head
+		if t.Data == "body" {
+			break
+		}
+		if t.Data != "meta" {
+			continue
+		}
+		var property, content string
+		for _, a := range t.Attr {
+			switch a.Key {
+			case "property", "name":
+				property = a.Val
+			case "content":
+				content = a.Val
+			}
+		}
+		switch property {
+		case "og:image", "og:image:url", "og:image:secure_url":
+			if image.URL == "" {
+				image.URL = content
+			}
+		case "og:image:alt":
+			if image.Alt == "" {
+				image.Alt = content
+			}
+		}
+	}
+	if image.URL == "" {
+		return nil, nil
+	}
+
+	// The URL should be absolute, but relative ones are common enough
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := url.Parse(strings.TrimSpace(image.URL))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing og:image")
+	}
+	image.URL = base.ResolveReference(ref).String()
+	return &image, nil
 }
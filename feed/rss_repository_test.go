@@ -0,0 +1,95 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dewey/webhook-receiver/database/databasetest"
+	"github.com/go-kit/log"
+)
+
+const testFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Blog</title>
+<item><guid>guid-1</guid><title>Hello</title><link>https://example.com/1</link></item>
+</channel></rss>`
+
+func Test_repository_Feed(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		// restart uses a new repository for the second request, like after a restart of the service
+		restart      bool
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:         "not modified",
+			statuses:     []int{http.StatusOK, http.StatusNotModified},
+			wantRequests: 2,
+		},
+		{
+			name:         "not modified after a restart",
+			statuses:     []int{http.StatusOK, http.StatusNotModified},
+			restart:      true,
+			wantRequests: 2,
+		},
+		{
+			name:         "retries server errors",
+			statuses:     []int{http.StatusOK, http.StatusServiceUnavailable, http.StatusNotModified},
+			wantRequests: 3,
+		},
+		{
+			name:         "gives up on client errors",
+			statuses:     []int{http.StatusOK, http.StatusNotFound},
+			wantRequests: 2,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[requests]
+				requests++
+				if r.Header.Get("User-Agent") != "test" {
+					t.Errorf("User-Agent = %q, want test", r.Header.Get("User-Agent"))
+				}
+				// Every request after the first one has to be conditional
+				if requests > 1 && (r.Header.Get("If-None-Match") != `"v1"` || r.Header.Get("If-Modified-Since") != "Wed, 07 Jun 2023 12:00:00 GMT") {
+					t.Errorf("request %d isn't conditional: %v", requests, r.Header)
+				}
+				if status != http.StatusOK {
+					w.WriteHeader(status)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Last-Modified", "Wed, 07 Jun 2023 12:00:00 GMT")
+				w.Write([]byte(testFeed))
+			}))
+			defer ts.Close()
+
+			db := databasetest.NewDB(t)
+			s := NewRepository(log.NewNopLogger(), ts.Client(), db, "test", 2)
+			s.backoff = 0
+			if _, err := s.Feed(context.Background(), ts.URL); err != nil {
+				t.Fatalf("Feed() error = %v", err)
+			}
+			if tt.restart {
+				s = NewRepository(log.NewNopLogger(), ts.Client(), db, "test", 2)
+				s.backoff = 0
+			}
+			feed, err := s.Feed(context.Background(), ts.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Feed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", requests, tt.wantRequests)
+			}
+			if !tt.wantErr && (len(feed.Items) != 1 || feed.Items[0].GUID != "guid-1") {
+				t.Errorf("Feed() didn't return the cached feed: %+v", feed)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ module github.com/dewey/webhook-receiver
 go 1.20
 
 require (
-	github.com/dghubble/go-twitter v0.0.0-20221024160433-0cc1e72ed6d8
 	github.com/dghubble/oauth1 v0.7.2
 	github.com/go-chi/chi/v5 v5.0.8
 	github.com/go-kit/log v0.2.1
@@ -14,21 +13,18 @@ require (
 	github.com/peterbourgon/ff/v3 v3.3.1
 	github.com/pkg/errors v0.9.1
 	github.com/pressly/goose/v3 v3.11.2
+	golang.org/x/net v0.4.0
 )
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
-	github.com/cenkalti/backoff/v4 v4.2.1 // indirect
-	github.com/dghubble/sling v1.4.0 // indirect
 	github.com/go-logfmt/logfmt v0.5.1 // indirect
-	github.com/google/go-querystring v1.1.0 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/mmcdole/goxpp v1.1.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/tomnomnom/linkheader v0.0.0-20180905144013-02ca5825eb80 // indirect
-	golang.org/x/net v0.4.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 )
@@ -0,0 +1,217 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// BlueskyPDSURL is the default personal data server for accounts on bsky.social
+const BlueskyPDSURL = "https://bsky.social"
+
+// blueskyMaxDescriptionLength is how long the description of a link card can be before we cut it off
+const blueskyMaxDescriptionLength = 300
+
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)(#[^\d\s\p{P}][^\s\p{P}]*)`)
+
+// BlueskySession is an authenticated session on a personal data server
+type BlueskySession struct {
+	AccessJwt string `json:"accessJwt"`
+	DID       string `json:"did"`
+	Handle    string `json:"handle"`
+}
+
+type blueskyRepository struct {
+	l           log.Logger
+	c           *http.Client
+	pdsURL      string
+	identifier  string
+	appPassword string
+	f           *Formatter
+}
+
+// NewBlueskyRepository initializes a new Bluesky notifier repository. The identifier is the handle or email of the
+// account, the password should be an app password and not the account password.
+func NewBlueskyRepository(l log.Logger, c *http.Client, pdsURL string, identifier string, appPassword string, f *Formatter) *blueskyRepository {
+	return &blueskyRepository{
+		l:           l,
+		c:           c,
+		pdsURL:      strings.TrimSuffix(pdsURL, "/"),
+		identifier:  identifier,
+		appPassword: appPassword,
+		f:           f,
+	}
+}
+
+func (s *blueskyRepository) String() string {
+	return "bluesky"
+}
+
+// CreateSession logs in with the app password. Access tokens are short-lived and we only post every now and then, so
+// we create a new session for every post instead of refreshing it.
+func (s *blueskyRepository) CreateSession(ctx context.Context) (*BlueskySession, error) {
+	var session BlueskySession
+	if err := s.xrpc(ctx, "", "com.atproto.server.createSession", "application/json", map[string]string{
+		"identifier": s.identifier,
+		"password":   s.appPassword,
+	}, &session); err != nil {
+		return nil, errors.Wrap(err, "creating session")
+	}
+	return &session, nil
+}
+
+func (s *blueskyRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	text, err := s.f.Format(item)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.CreateSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	record := map[string]interface{}{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	if facets := blueskyFacets(text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+	if item.Link != "" {
+		record["embed"] = s.externalEmbed(ctx, session, item)
+	}
+
+	var created struct {
+		URI string `json:"uri"`
+		CID string `json:"cid"`
+	}
+	if err := s.xrpc(ctx, session.AccessJwt, "com.atproto.repo.createRecord", "application/json", map[string]interface{}{
+		"repo":       session.DID,
+		"collection": "app.bsky.feed.post",
+		"record":     record,
+	}, &created); err != nil {
+		return nil, errors.Wrap(err, "creating post")
+	}
+
+	// The URI looks like at://did:plc:xyz/app.bsky.feed.post/<rkey>, the web app uses the rkey in its links
+	rkey := created.URI[strings.LastIndex(created.URI, "/")+1:]
+	postURL := fmt.Sprintf("https://bsky.app/profile/%s/post/%s", session.Handle, rkey)
+	level.Info(s.l).Log("msg", "bluesky post successfully sent", "uri", created.URI, "url", postURL)
+	return &Result{
+		ID:          created.URI,
+		URL:         postURL,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// externalEmbed builds the link card for the item. If the thumbnail can't be uploaded the card is posted without it.
+func (s *blueskyRepository) externalEmbed(ctx context.Context, session *BlueskySession, item Item) map[string]interface{} {
+	external := map[string]interface{}{
+		"uri":         item.Link,
+		"title":       item.Title,
+		"description": truncateGraphemes(blueskyMaxDescriptionLength, stripHTML(item.Summary)),
+	}
+	if item.Image != "" {
+		thumb, err := s.uploadImage(ctx, session, item.Image)
+		if err != nil {
+			level.Error(s.l).Log("msg", "error uploading thumbnail, posting link card without it", "image", item.Image, "err", err)
+		} else {
+			external["thumb"] = thumb
+		}
+	}
+	return map[string]interface{}{
+		"$type":    "app.bsky.embed.external",
+		"external": external,
+	}
+}
+
+// uploadImage downloads the image and uploads it as a blob, the returned blob reference can be used in records
+func (s *blueskyRepository) uploadImage(ctx context.Context, session *BlueskySession, imageURL string) (json.RawMessage, error) {
+	img, err := downloadImage(ctx, imageURL, blueskyImageLimits)
+	if err != nil {
+		return nil, err
+	}
+	var uploaded struct {
+		Blob json.RawMessage `json:"blob"`
+	}
+	if err := s.xrpc(ctx, session.AccessJwt, "com.atproto.repo.uploadBlob", img.contentType, img.data, &uploaded); err != nil {
+		return nil, errors.Wrap(err, "uploading blob")
+	}
+	return uploaded.Blob, nil
+}
+
+// xrpc calls a procedure on the PDS. Bodies that are []byte are sent as is, everything else is encoded as JSON.
+func (s *blueskyRepository) xrpc(ctx context.Context, accessJwt string, method string, contentType string, body interface{}, v interface{}) error {
+	b, ok := body.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.pdsURL+"/xrpc/"+method, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if accessJwt != "" {
+		req.Header.Set("Authorization", "Bearer "+accessJwt)
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var xrpcErr struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&xrpcErr); err == nil && xrpcErr.Error != "" {
+			return errors.Errorf("unexpected status code %d from bluesky: %s: %s", resp.StatusCode, xrpcErr.Error, xrpcErr.Message)
+		}
+		return errors.Errorf("unexpected status code %d from bluesky", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// blueskyFacets finds links and hashtags in the text. Bluesky doesn't detect them itself, they have to be annotated
+// with their UTF-8 byte offsets.
+func blueskyFacets(text string) []map[string]interface{} {
+	var facets []map[string]interface{}
+	for _, m := range urlPattern.FindAllStringIndex(text, -1) {
+		// Punctuation at the end is most likely not part of the link, e.g. "(https://example.com)."
+		uri := strings.TrimRight(text[m[0]:m[1]], ".,;:!?)\"'“”")
+		facets = append(facets, blueskyFacet(m[0], m[0]+len(uri), map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#link",
+			"uri":   uri,
+		}))
+	}
+	for _, m := range hashtagPattern.FindAllStringSubmatchIndex(text, -1) {
+		facets = append(facets, blueskyFacet(m[2], m[3], map[string]interface{}{
+			"$type": "app.bsky.richtext.facet#tag",
+			"tag":   text[m[2]+1 : m[3]],
+		}))
+	}
+	return facets
+}
+
+func blueskyFacet(start int, end int, feature map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"index": map[string]int{
+			"byteStart": start,
+			"byteEnd":   end,
+		},
+		"features": []map[string]interface{}{feature},
+	}
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func Test_blueskyRepository_Post(t *testing.T) {
+	var record struct {
+		Text   string `json:"text"`
+		Facets []struct {
+			Index struct {
+				ByteStart int `json:"byteStart"`
+				ByteEnd   int `json:"byteEnd"`
+			} `json:"index"`
+			Features []map[string]string `json:"features"`
+		} `json:"facets"`
+		Embed struct {
+			Type     string `json:"$type"`
+			External struct {
+				URI         string          `json:"uri"`
+				Title       string          `json:"title"`
+				Description string          `json:"description"`
+				Thumb       json.RawMessage `json:"thumb"`
+			} `json:"external"`
+		} `json:"embed"`
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\r\n\x1a\n fake image"))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.server.createSession", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["identifier"] != "annoying.technology" || body["password"] != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"AuthenticationRequired","message":"Invalid identifier or password"}`))
+			return
+		}
+		w.Write([]byte(`{"accessJwt":"access","refreshJwt":"refresh","did":"did:plc:abc","handle":"annoying.technology"}`))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.repo.uploadBlob", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" || r.Header.Get("Content-Type") != "image/png" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"blob":{"$type":"blob","ref":{"$link":"bafkrei"},"mimeType":"image/png","size":19}}`))
+	})
+	mux.HandleFunc("/xrpc/com.atproto.repo.createRecord", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Repo       string          `json:"repo"`
+			Collection string          `json:"collection"`
+			Record     json.RawMessage `json:"record"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Header.Get("Authorization") != "Bearer access" || body.Repo != "did:plc:abc" || body.Collection != "app.bsky.feed.post" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(body.Record, &record)
+		w.Write([]byte(`{"uri":"at://did:plc:abc/app.bsky.feed.post/3k2yihcrp6f2c","cid":"bafyrei"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	formatter, err := NewFormatter("bluesky", "Grüße: {{ .Title }} {{ .Link }} {{ hashtagify .Categories }}", BlueskyLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewBlueskyRepository(log.NewNopLogger(), server.Client(), server.URL, "annoying.technology", "app-password", formatter)
+	result, err := s.Post(context.Background(), Item{
+		Title:      "Something annoying",
+		Summary:    "<p>A summary</p>",
+		Link:       "https://annoying.technology/posts/1/",
+		Image:      server.URL + "/image.png",
+		Categories: []string{"apple"},
+	})
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if want := "https://bsky.app/profile/annoying.technology/post/3k2yihcrp6f2c"; result.URL != want {
+		t.Errorf("Post() url = %v, want %v", result.URL, want)
+	}
+
+	if want := "Grüße: Something annoying https://annoying.technology/posts/1/ #Apple"; record.Text != want {
+		t.Fatalf("text = %q, want %q", record.Text, want)
+	}
+	if len(record.Facets) != 2 {
+		t.Fatalf("facets = %+v, want link and tag", record.Facets)
+	}
+	// Offsets are in bytes, "ü" and "ß" are two bytes each
+	link := record.Facets[0]
+	if got := record.Text[link.Index.ByteStart:link.Index.ByteEnd]; got != "https://annoying.technology/posts/1/" || link.Features[0]["uri"] != got {
+		t.Errorf("link facet covers %q, feature %v", got, link.Features[0])
+	}
+	tag := record.Facets[1]
+	if got := record.Text[tag.Index.ByteStart:tag.Index.ByteEnd]; got != "#Apple" || tag.Features[0]["tag"] != "Apple" {
+		t.Errorf("tag facet covers %q, feature %v", got, tag.Features[0])
+	}
+
+	if record.Embed.Type != "app.bsky.embed.external" || record.Embed.External.Title != "Something annoying" || record.Embed.External.Description != "A summary" {
+		t.Errorf("embed = %+v", record.Embed)
+	}
+	if len(record.Embed.External.Thumb) == 0 {
+		t.Errorf("embed has no thumbnail")
+	}
+}
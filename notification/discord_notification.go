@@ -0,0 +1,117 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// discordMaxTitleLength is the maximum length of the title of a Discord embed
+const discordMaxTitleLength = 256
+
+type discordRepository struct {
+	l          log.Logger
+	c          *http.Client
+	webhookURL string
+	f          *Formatter
+}
+
+// NewDiscordRepository initializes a new Discord notifier repository posting embeds to a channel webhook. The template
+// renders the description of the embed.
+func NewDiscordRepository(l log.Logger, c *http.Client, webhookURL string, f *Formatter) *discordRepository {
+	return &discordRepository{
+		l:          l,
+		c:          c,
+		webhookURL: webhookURL,
+		f:          f,
+	}
+}
+
+func (s *discordRepository) String() string {
+	return "discord"
+}
+
+func (s *discordRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	text, err := s.f.Format(item)
+	if err != nil {
+		return nil, err
+	}
+
+	embed := map[string]interface{}{
+		// The title is decoded like the description, the embed shows it as plain text
+		"title":       truncateGraphemes(discordMaxTitleLength, stripHTML(item.Title)),
+		"url":         item.Link,
+		"description": text,
+	}
+	if item.Author != "" {
+		embed["author"] = map[string]string{"name": item.Author}
+	}
+	if item.Image != "" {
+		embed["thumbnail"] = map[string]string{"url": item.Image}
+	}
+	if !item.Published.IsZero() {
+		embed["timestamp"] = item.Published.UTC().Format(time.RFC3339)
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		"embeds": []interface{}{embed},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// With wait=true the webhook responds with the created message instead of an empty 204
+	u, err := url.Parse(s.webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("wait", "true")
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "posting discord message")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && apiErr.Message != "" {
+			return nil, errors.Errorf("unexpected status code %d from discord: %s", resp.StatusCode, apiErr.Message)
+		}
+		return nil, errors.Errorf("unexpected status code %d from discord", resp.StatusCode)
+	}
+	var message struct {
+		ID        string `json:"id"`
+		ChannelID string `json:"channel_id"`
+		GuildID   string `json:"guild_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, errors.Wrap(err, "decoding discord message")
+	}
+
+	// Links to messages need the server, which isn't always part of the response
+	var messageURL string
+	if message.GuildID != "" {
+		messageURL = fmt.Sprintf("https://discord.com/channels/%s/%s/%s", message.GuildID, message.ChannelID, message.ID)
+	}
+	level.Info(s.l).Log("msg", "discord message successfully sent", "id", message.ID, "url", messageURL)
+	return &Result{
+		ID:          message.ID,
+		URL:         messageURL,
+		PublishedAt: time.Now(),
+	}, nil
+}
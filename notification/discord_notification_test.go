@@ -0,0 +1,68 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func Test_discordRepository_Post(t *testing.T) {
+	var message struct {
+		Embeds []struct {
+			Title       string            `json:"title"`
+			URL         string            `json:"url"`
+			Description string            `json:"description"`
+			Author      map[string]string `json:"author"`
+			Thumbnail   map[string]string `json:"thumbnail"`
+			Timestamp   string            `json:"timestamp"`
+		} `json:"embeds"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "true" || r.URL.Query().Get("thread_id") != "42" {
+			t.Errorf("query = %v, want wait and the configured thread", r.URL.Query())
+		}
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message":"Cannot send an empty message","code":50006}`))
+			return
+		}
+		w.Write([]byte(`{"id":"1100","channel_id":"200","guild_id":"300"}`))
+	}))
+	defer server.Close()
+
+	formatter, err := NewFormatter("discord", MessageTemplate, DiscordLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDiscordRepository(log.NewNopLogger(), server.Client(), server.URL+"/api/webhooks/1/token?thread_id=42", formatter)
+	result, err := s.Post(context.Background(), Item{
+		Title:     "Something &amp; annoying",
+		Summary:   "<p>A summary</p>",
+		Author:    "Philipp",
+		Link:      "https://annoying.technology/posts/1/",
+		Image:     "https://annoying.technology/posts/1/image.png",
+		Published: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if result.ID != "1100" || result.URL != "https://discord.com/channels/300/200/1100" {
+		t.Errorf("Post() = %+v", result)
+	}
+
+	if len(message.Embeds) != 1 {
+		t.Fatalf("embeds = %+v, want one", message.Embeds)
+	}
+	embed := message.Embeds[0]
+	if embed.Title != "Something & annoying" || embed.URL != "https://annoying.technology/posts/1/" || embed.Description != "A summary" {
+		t.Errorf("embed = %+v", embed)
+	}
+	if embed.Author["name"] != "Philipp" || embed.Thumbnail["url"] == "" || embed.Timestamp != "2023-06-01T12:00:00Z" {
+		t.Errorf("embed = %+v, want author, thumbnail and timestamp", embed)
+	}
+}
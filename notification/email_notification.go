@@ -0,0 +1,257 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EmailSubjectTemplate is the default subject of emails
+	EmailSubjectTemplate = "{{ stripHTML .Title }}"
+	// EmailTextTemplate is the default plain text body of emails
+	EmailTextTemplate = "{{ stripHTML .Title }}\n\n{{ stripHTML (or .Content .Summary) }}\n\n{{ .Link }}"
+	// EmailHTMLTemplate is the default HTML body of emails, the content of the feed is trusted and included as is
+	EmailHTMLTemplate = `<h1><a href="{{ .Link }}">{{ stripHTML .Title }}</a></h1>
+{{ safeHTML (or .Content .Summary) }}
+<p><a href="{{ .Link }}">{{ .Link }}</a></p>`
+)
+
+// SMTP connection security modes
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// SMTPConfig describes the mail server we send emails through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// Security is either SMTPStartTLS, SMTPTLS for implicit TLS (usually port 465) or SMTPNone
+	Security string
+}
+
+type emailRepository struct {
+	l       log.Logger
+	config  SMTPConfig
+	from    string
+	to      []string
+	subject *Formatter
+	text    *Formatter
+	html    *htmltemplate.Template
+}
+
+// NewEmailRepository initializes a new email notifier repository sending a multipart text and HTML email to every
+// recipient, which can also be the address of a mailing list. The recipients don't see each other.
+func NewEmailRepository(l log.Logger, config SMTPConfig, from string, to []string, subject *Formatter, text *Formatter, html *htmltemplate.Template) *emailRepository {
+	return &emailRepository{
+		l:       l,
+		config:  config,
+		from:    from,
+		to:      to,
+		subject: subject,
+		text:    text,
+		html:    html,
+	}
+}
+
+// NewHTMLTemplate parses a html/template for the HTML body of emails. Besides the fields of Item it has access to
+// stripHTML and safeHTML <html>, which includes HTML without escaping it.
+func NewHTMLTemplate(name string, text string) (*htmltemplate.Template, error) {
+	t, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap{
+		"stripHTML": stripHTML,
+		"safeHTML":  func(s string) htmltemplate.HTML { return htmltemplate.HTML(s) },
+	}).Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s template", name)
+	}
+	return t, nil
+}
+
+func (s *emailRepository) String() string {
+	return "email"
+}
+
+func (s *emailRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	messageID := emailMessageID(item.Feed, item.GUID, s.from)
+	message, err := s.message(item, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(ctx, message); err != nil {
+		return nil, errors.Wrap(err, "sending email")
+	}
+	level.Info(s.l).Log("msg", "email successfully sent", "message_id", messageID, "recipients", len(s.to))
+	return &Result{
+		ID:          messageID,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// message renders the item into a multipart/alternative email
+func (s *emailRepository) message(item Item, messageID string) ([]byte, error) {
+	subject, err := s.subject.Format(item)
+	if err != nil {
+		return nil, err
+	}
+	text, err := s.text.Format(item)
+	if err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	if err := s.html.Execute(&html, item); err != nil {
+		return nil, errors.Wrapf(err, "executing %s template", s.html.Name())
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html.String()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	for _, header := range [][2]string{
+		{"From", s.from},
+		{"To", emailToHeader(s.to)},
+		{"Subject", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(subject, "\n", " "))},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + mw.Boundary()},
+	} {
+		fmt.Fprintf(&b, "%s: %s\r\n", header[0], header[1])
+	}
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return b.Bytes(), nil
+}
+
+// send delivers the message to all recipients through the mail server
+func (s *emailRepository) send(ctx context.Context, message []byte) error {
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	tlsConfig := &tls.Config{ServerName: s.config.Host}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.config.Security == SMTPTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.config.Security == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("mail server doesn't support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return errors.Wrap(err, "starting tls")
+		}
+	}
+	if s.config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+	if err := c.Mail(emailAddress(s.from)); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(emailAddress(to)); err != nil {
+			return errors.Wrapf(err, "adding recipient %s", to)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailMessageID derives the Message-ID from the feed and GUID of the item, so copies of the same post can be recognized
+func emailMessageID(feed string, guid string, from string) string {
+	domain := "webhook-receiver"
+	if i := strings.LastIndex(emailAddress(from), "@"); i != -1 {
+		domain = emailAddress(from)[i+1:]
+	}
+	sum := sha256.Sum256([]byte(feed + "\n" + guid))
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(sum[:16]), domain)
+}
+
+// emailToHeader is the To header of the emails. A single recipient, usually a mailing list, is shown as is. Individual
+// recipients only get a copy like with Bcc, they shouldn't see who else does.
+func emailToHeader(to []string) string {
+	if len(to) == 1 {
+		return to[0]
+	}
+	return "undisclosed-recipients:;"
+}
+
+// emailAddress returns the address of "Name <name@example.com>"
+func emailAddress(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.Address
+	}
+	return strings.TrimSpace(s)
+}
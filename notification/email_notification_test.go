@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+// smtpSink is a minimal SMTP server that accepts every message
+type smtpSink struct {
+	listener   net.Listener
+	recipients []string
+	auth       string
+	data       string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{listener: l}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP sink")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			verb, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(verb) {
+			case "EHLO":
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				s.auth = arg
+				tp.PrintfLine("235 2.7.0 Authentication successful")
+			case "MAIL":
+				tp.PrintfLine("250 2.1.0 Ok")
+			case "RCPT":
+				s.recipients = append(s.recipients, arg)
+				tp.PrintfLine("250 2.1.5 Ok")
+			case "DATA":
+				tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+				b, _ := io.ReadAll(tp.DotReader())
+				s.data = string(b)
+				tp.PrintfLine("250 2.0.0 Ok: queued")
+			case "QUIT":
+				tp.PrintfLine("221 2.0.0 Bye")
+				return
+			default:
+				tp.PrintfLine("502 5.5.2 Error: command not recognized")
+			}
+		}
+	}()
+	return s
+}
+
+func Test_emailRepository_Post(t *testing.T) {
+	sink := newSMTPSink(t)
+	defer sink.listener.Close()
+	host, port, _ := net.SplitHostPort(sink.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	subject, _ := NewFormatter("email-subject", EmailSubjectTemplate, Length{})
+	text, _ := NewFormatter("email-text", EmailTextTemplate, Length{})
+	html, err := NewHTMLTemplate("email-html", EmailHTMLTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewEmailRepository(log.NewNopLogger(), SMTPConfig{
+		Host:     host,
+		Port:     p,
+		Username: "user",
+		Password: "password",
+		Security: SMTPNone,
+	}, "Annoying Technology <posts@annoying.technology>", []string{"a@example.com", "B <b@example.com>"}, subject, text, html)
+	result, err := s.Post(context.Background(), Item{
+		GUID:    "https://annoying.technology/posts/1/",
+		Title:   "Grüße &amp; <em>more</em>",
+		Content: "<p>Some <em>content</em></p>",
+		Link:    "https://annoying.technology/posts/1/",
+	})
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+
+	if sink.auth == "" {
+		t.Errorf("client didn't authenticate")
+	}
+	if want := "TO:<a@example.com> TO:<b@example.com>"; strings.Join(sink.recipients, " ") != want {
+		t.Errorf("recipients = %v, want %v", sink.recipients, want)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(sink.data))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "undisclosed-recipients:;" || strings.Contains(to, "example.com") {
+		t.Errorf("To = %q, want the recipients hidden", to)
+	}
+	if got, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); got != "Grüße & more" {
+		t.Errorf("Subject = %q", got)
+	}
+	if msg.Header.Get("Message-ID") != result.ID || !strings.HasSuffix(result.ID, "@annoying.technology>") {
+		t.Errorf("Message-ID = %q, result = %q", msg.Header.Get("Message-ID"), result.ID)
+	}
+
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	parts := make(map[string]string)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		b, _ := io.ReadAll(part)
+		parts[part.Header.Get("Content-Type")] = string(b)
+	}
+	if want := "Grüße & more\n\nSome content\n\nhttps://annoying.technology/posts/1/"; parts["text/plain; charset=utf-8"] != want {
+		t.Errorf("text = %q, want %q", parts["text/plain; charset=utf-8"], want)
+	}
+	if html := parts["text/html; charset=utf-8"]; !strings.Contains(html, "Grüße &amp; more</a></h1>") || !strings.Contains(html, "<p>Some <em>content</em></p>") {
+		t.Errorf("html = %q", html)
+	}
+}
+
+func Test_emailToHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		to   []string
+		want string
+	}{
+		{name: "mailing list", to: []string{"List <list@example.com>"}, want: "List <list@example.com>"},
+		{name: "recipients", to: []string{"a@example.com", "b@example.com"}, want: "undisclosed-recipients:;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := emailToHeader(tt.to); got != tt.want {
+				t.Errorf("emailToHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_emailMessageID(t *testing.T) {
+	from := "Annoying Technology <posts@annoying.technology>"
+	first := emailMessageID("default", "guid-1", from)
+	if again := emailMessageID("default", "guid-1", from); again != first {
+		t.Errorf("emailMessageID() = %s, then %s, want the same id for the same item", first, again)
+	}
+	if other := emailMessageID("podcast", "guid-1", from); other == first {
+		t.Errorf("emailMessageID() = %s for both feeds, want different ids", other)
+	}
+	if !strings.HasSuffix(first, "@annoying.technology>") {
+		t.Errorf("emailMessageID() = %s, want the domain of the sender", first)
+	}
+}
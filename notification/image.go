@@ -0,0 +1,74 @@
+package notification
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// imageClient downloads the images of items. The clients of the notifiers can't be used for that, they might add
+// credentials to every request.
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// imageLimits are the formats and the size an image can have to be uploaded to a platform
+type imageLimits struct {
+	maxSize int64
+	types   []string
+}
+
+var (
+	// twitterImageLimits are the limits of the X API media upload for images
+	twitterImageLimits = imageLimits{maxSize: 5 << 20, types: []string{"image/jpeg", "image/png", "image/gif", "image/webp"}}
+	// mastodonImageLimits are the limits of a default Mastodon instance
+	mastodonImageLimits = imageLimits{maxSize: 16 << 20, types: []string{"image/jpeg", "image/png", "image/gif", "image/webp"}}
+	// blueskyImageLimits are the limits of blobs used as images or thumbnails
+	blueskyImageLimits = imageLimits{maxSize: 1000000, types: []string{"image/jpeg", "image/png", "image/gif", "image/webp"}}
+)
+
+// image is a downloaded image that passed the checks of a platform
+type image struct {
+	data        []byte
+	contentType string
+}
+
+// downloadImage downloads the image and checks that the platform supports it. The content type is detected from the
+// data, as servers often send something generic like application/octet-stream.
+func downloadImage(ctx context.Context, imageURL string, limits imageLimits) (*image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d downloading image", resp.StatusCode)
+	}
+	if resp.ContentLength > limits.maxSize {
+		return nil, errors.Errorf("image is larger than %d bytes", limits.maxSize)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limits.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > limits.maxSize {
+		return nil, errors.Errorf("image is larger than %d bytes", limits.maxSize)
+	}
+
+	contentType := http.DetectContentType(b)
+	for _, t := range limits.types {
+		if strings.HasPrefix(contentType, t) {
+			return &image{
+				data:        b,
+				contentType: t,
+			}, nil
+		}
+	}
+	return nil, errors.Errorf("image type %s is not supported", contentType)
+}
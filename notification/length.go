@@ -0,0 +1,203 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// twitterMaxLength is the maximum weighted length of a tweet
+	twitterMaxLength = 280
+	// shortenedURLLength is how many characters a link counts as on Twitter (t.co) and Mastodon, regardless of its length
+	shortenedURLLength = 23
+	// mastodonDefaultMaxLength is the limit of a default Mastodon instance
+	mastodonDefaultMaxLength = 500
+	// blueskyMaxLength is the maximum number of graphemes in a Bluesky post
+	blueskyMaxLength = 300
+	// slackMaxLength is the maximum length of the text of a Slack section block
+	slackMaxLength = 3000
+	// discordMaxLength is the maximum length of the description of a Discord embed
+	discordMaxLength = 4096
+	// telegramMaxLength is the maximum length of a Telegram message
+	telegramMaxLength = 4096
+	// telegramMaxCaptionLength is the maximum length of the caption of a Telegram photo
+	telegramMaxCaptionLength = 1024
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// Length describes how a platform counts the length of a post and what the maximum is. The zero value has no limit.
+type Length struct {
+	Max   int
+	Count func(text string) int
+}
+
+// Fits checks if the text is within the limit
+func (l Length) Fits(text string) bool {
+	if l.Max == 0 || l.Count == nil {
+		return true
+	}
+	return l.Count(text) <= l.Max
+}
+
+// TwitterLength counts like twitter-text: Every link is 23 characters, emoji and characters outside of Latin, General
+// Punctuation and similar ranges (e.g. CJK) count as two.
+func TwitterLength() Length {
+	return Length{
+		Max: twitterMaxLength,
+		Count: func(text string) int {
+			var weight int
+			for _, g := range graphemes(replaceURLs(text, shortenedURLLength)) {
+				if isEmoji(g) {
+					weight += 2
+					continue
+				}
+				for _, r := range g {
+					weight += twitterRuneWeight(r)
+				}
+			}
+			return weight
+		},
+	}
+}
+
+// MastodonLength counts graphemes with every link counting as urlLength characters
+func MastodonLength(max int, urlLength int) Length {
+	if max == 0 {
+		max = mastodonDefaultMaxLength
+	}
+	if urlLength == 0 {
+		urlLength = shortenedURLLength
+	}
+	return Length{
+		Max: max,
+		Count: func(text string) int {
+			return graphemeCount(replaceURLs(text, urlLength))
+		},
+	}
+}
+
+// BlueskyLength counts graphemes, links count with their full length
+func BlueskyLength() Length {
+	return Length{
+		Max:   blueskyMaxLength,
+		Count: graphemeCount,
+	}
+}
+
+// FetchMastodonLimits returns the maximum characters of a post and how many characters a link counts as from the
+// instance API. Instances that don't support the v2 API fall back to the defaults.
+func FetchMastodonLimits(ctx context.Context, c *http.Client, server string) (int, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+"/api/v2/instance", nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "fetching instance information")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return mastodonDefaultMaxLength, shortenedURLLength, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, errors.Errorf("unexpected status code %d fetching instance information", resp.StatusCode)
+	}
+	var instance struct {
+		Configuration struct {
+			Statuses struct {
+				MaxCharacters            int `json:"max_characters"`
+				CharactersReservedPerURL int `json:"characters_reserved_per_url"`
+			} `json:"statuses"`
+		} `json:"configuration"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&instance); err != nil {
+		return 0, 0, errors.Wrap(err, "decoding instance information")
+	}
+	max, urlLength := instance.Configuration.Statuses.MaxCharacters, instance.Configuration.Statuses.CharactersReservedPerURL
+	if max == 0 {
+		max = mastodonDefaultMaxLength
+	}
+	if urlLength == 0 {
+		urlLength = shortenedURLLength
+	}
+	return max, urlLength, nil
+}
+
+// replaceURLs replaces every link with a placeholder of the length the platform counts it as
+func replaceURLs(text string, length int) string {
+	return urlPattern.ReplaceAllLiteralString(text, strings.Repeat("x", length))
+}
+
+// twitterRuneWeight returns the weight of a code point, as defined in the twitter-text v3 configuration
+func twitterRuneWeight(r rune) int {
+	switch {
+	case r <= 4351, r >= 8192 && r <= 8205, r >= 8208 && r <= 8223, r >= 8242 && r <= 8247:
+		return 1
+	}
+	return 2
+}
+
+// isEmoji checks if a grapheme starts with an emoji, this only looks at the common emoji blocks
+func isEmoji(g string) bool {
+	r, _ := utf8.DecodeRuneInString(g)
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff, r >= 0x2600 && r <= 0x27bf, r >= 0x2300 && r <= 0x23ff, r >= 0x2b00 && r <= 0x2bff:
+		return true
+	}
+	return false
+}
+
+// SlackLength counts characters like the text of a Slack section block, after &, < and > are escaped for mrkdwn
+func SlackLength() Length {
+	return Length{
+		Max: slackMaxLength,
+		Count: func(text string) int {
+			return graphemeCount(slackEscaper.Replace(text))
+		},
+	}
+}
+
+// DiscordLength counts characters like the description of a Discord embed
+func DiscordLength() Length {
+	return Length{
+		Max:   discordMaxLength,
+		Count: graphemeCount,
+	}
+}
+
+// TelegramLength counts UTF-16 code units like Telegram does for the text of a message. HTML tags are counted too,
+// even though Telegram only counts the text they contain.
+func TelegramLength() Length {
+	return Length{
+		Max:   telegramMaxLength,
+		Count: utf16Count,
+	}
+}
+
+// TelegramCaptionLength counts like TelegramLength for the caption of a photo
+func TelegramCaptionLength() Length {
+	return Length{
+		Max:   telegramMaxCaptionLength,
+		Count: utf16Count,
+	}
+}
+
+// utf16Count returns the number of UTF-16 code units of the text, characters outside the BMP like emoji count as two
+func utf16Count(text string) int {
+	var n int
+	for _, r := range text {
+		if r > 0xFFFF {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
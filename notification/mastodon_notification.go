@@ -3,7 +3,11 @@ package notification
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-mastodon"
 
@@ -12,16 +16,52 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	// mastodonMaxDescriptionLength is the maximum length of the description of a media attachment
+	mastodonMaxDescriptionLength = 1500
+	// mastodonMinScheduleDelay is how far in the future statuses have to be scheduled, with some leeway
+	mastodonMinScheduleDelay = 6 * time.Minute
+)
+
+// Visibilities of toots
+const (
+	MastodonPublic   = "public"
+	MastodonUnlisted = "unlisted"
+	MastodonPrivate  = "private"
+	MastodonDirect   = "direct"
+)
+
+// MastodonOptions are the defaults for every toot. Items can override them with their categories.
+type MastodonOptions struct {
+	// Visibility is one of MastodonPublic, MastodonUnlisted, MastodonPrivate or MastodonDirect, the default of the
+	// account is used if it's empty
+	Visibility string
+	// SpoilerText is the content warning of every toot
+	SpoilerText string
+	// Sensitive marks media as sensitive
+	Sensitive bool
+	// Language is the ISO 639 language of toots, if it's empty the language of the item is used
+	Language string
+	// CategoryContentWarnings are content warnings for items with the category, e.g. "nsfw" to "NSFW"
+	CategoryContentWarnings map[string]string
+	// CategoryVisibilities are visibilities for items with the category, e.g. "announcement" to "unlisted"
+	CategoryVisibilities map[string]string
+}
+
 type mastodonRepository struct {
-	l log.Logger
-	c *mastodon.Client
+	l       log.Logger
+	c       *mastodon.Client
+	f       *Formatter
+	options MastodonOptions
 }
 
 // NewMastodonRepository initializes a new Mastodon notifier repository
-func NewMastodonRepository(l log.Logger, c *mastodon.Client) *mastodonRepository {
+func NewMastodonRepository(l log.Logger, c *mastodon.Client, f *Formatter, options MastodonOptions) *mastodonRepository {
 	return &mastodonRepository{
-		l: l,
-		c: c,
+		l:       l,
+		c:       c,
+		f:       f,
+		options: options,
 	}
 }
 
@@ -29,41 +69,151 @@ func (s *mastodonRepository) String() string {
 	return "mastodon"
 }
 
-func (s *mastodonRepository) Post(ctx context.Context, text string, author string, url string) error {
-	// We split into words, and add as many words while trying to stay roughly under 100 characters for the post
-	var length int
-	var summaryTokens []string
-	for _, t := range strings.Split(text, " ") {
-		runes := bytes.Runes([]byte(t))
-		// If length of toot is below 100, we add tokens to the list
-		if length <= 100 {
-			length += len(runes)
-			summaryTokens = append(summaryTokens, t)
+func (s *mastodonRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	toot, err := s.prepare(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.c.PostStatus(ctx, toot)
+	if err != nil {
+		return nil, errors.Wrap(err, "posting status update")
+	}
+
+	level.Info(s.l).Log("msg", "toot successfully sent", "id", status.ID, "url", status.URL)
+	return &Result{
+		ID:          string(status.ID),
+		URL:         status.URL,
+		PublishedAt: status.CreatedAt,
+	}, nil
+}
+
+// Schedule creates a scheduled status that the instance publishes at the given time. The client library doesn't send
+// scheduled_at, so we call the API ourselves. The result has no URL, it only exists once the status is published.
+func (s *mastodonRepository) Schedule(ctx context.Context, item Item, at time.Time) (*Result, error) {
+	// Statuses have to be scheduled at least 5 minutes in the future
+	if earliest := time.Now().Add(mastodonMinScheduleDelay); at.Before(earliest) {
+		at = earliest
+	}
+	toot, err := s.prepare(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("status", toot.Status)
+	params.Set("scheduled_at", at.UTC().Format(time.RFC3339))
+	for _, id := range toot.MediaIDs {
+		params.Add("media_ids[]", string(id))
+	}
+	if toot.Visibility != "" {
+		params.Set("visibility", toot.Visibility)
+	}
+	if toot.Language != "" {
+		params.Set("language", toot.Language)
+	}
+	if toot.Sensitive {
+		params.Set("sensitive", "true")
+	}
+	if toot.SpoilerText != "" {
+		params.Set("spoiler_text", toot.SpoilerText)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(s.c.Config.Server, "/")+"/api/v1/statuses", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+s.c.Config.AccessToken)
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "scheduling status update")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			return nil, errors.Errorf("unexpected status code %d from mastodon: %s", resp.StatusCode, apiErr.Error)
+		}
+		return nil, errors.Errorf("unexpected status code %d from mastodon", resp.StatusCode)
+	}
+	var scheduled struct {
+		ID          string    `json:"id"`
+		ScheduledAt time.Time `json:"scheduled_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&scheduled); err != nil {
+		return nil, errors.Wrap(err, "decoding scheduled status")
+	}
+
+	level.Info(s.l).Log("msg", "toot successfully scheduled", "id", scheduled.ID, "scheduled_at", scheduled.ScheduledAt)
+	return &Result{
+		ID:          scheduled.ID,
+		PublishedAt: scheduled.ScheduledAt,
+	}, nil
+}
+
+// prepare renders the toot of the item and uploads its image
+func (s *mastodonRepository) prepare(ctx context.Context, item Item) (*mastodon.Toot, error) {
+	text, err := s.f.Format(item)
+	if err != nil {
+		return nil, err
+	}
+	toot := s.toot(item)
+	toot.Status = text
+	if item.Image != "" {
+		attachment, err := s.uploadImage(ctx, item)
+		if err != nil {
+			level.Error(s.l).Log("msg", "error uploading image, posting toot without it", "image", item.Image, "err", err)
 		} else {
-			// Only if the word is shorter than 5 do we also still add it to the list
-			if len(runes) < 5 {
-				summaryTokens = append(summaryTokens, t)
-				length += len(runes)
-				break
-			} else {
-				break
-			}
+			toot.MediaIDs = []mastodon.ID{attachment.ID}
+		}
+	}
+	return toot, nil
+}
+
+// toot applies the options and the overrides of the item's categories
+func (s *mastodonRepository) toot(item Item) *mastodon.Toot {
+	toot := &mastodon.Toot{
+		Visibility:  s.options.Visibility,
+		SpoilerText: s.options.SpoilerText,
+		Sensitive:   s.options.Sensitive,
+		Language:    mastodonLanguage(s.options.Language),
+	}
+	if toot.Language == "" {
+		toot.Language = mastodonLanguage(item.Language)
+	}
+	for _, category := range item.Categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if cw, ok := s.options.CategoryContentWarnings[category]; ok && toot.SpoilerText == "" {
+			toot.SpoilerText = cw
+			toot.Sensitive = true
+		}
+		if visibility, ok := s.options.CategoryVisibilities[category]; ok {
+			toot.Visibility = visibility
 		}
 	}
-	status, err := s.c.PostStatus(ctx, &mastodon.Toot{
-		Status: "”" + strings.Join(summaryTokens, " ") + "...“" + "\n\n" + url,
-		//InReplyToID: "",
-		//MediaIDs:    nil,
-		//Sensitive:   false,
-		//SpoilerText: "",
-		//Visibility:  "",
-		//ScheduledAt: nil,
-		//Poll:        nil,
+	return toot
+}
+
+// mastodonLanguage turns a language tag like "en-US" into the ISO 639 code Mastodon expects
+func mastodonLanguage(language string) string {
+	language, _, _ = strings.Cut(strings.TrimSpace(language), "-")
+	language, _, _ = strings.Cut(language, "_")
+	return strings.ToLower(language)
+}
+
+// uploadImage downloads the image of the item and uploads it as a media attachment with the alt text as description
+func (s *mastodonRepository) uploadImage(ctx context.Context, item Item) (*mastodon.Attachment, error) {
+	img, err := downloadImage(ctx, item.Image, mastodonImageLimits)
+	if err != nil {
+		return nil, err
+	}
+	attachment, err := s.c.UploadMediaFromMedia(ctx, &mastodon.Media{
+		File:        bytes.NewReader(img.data),
+		Description: truncateGraphemes(mastodonMaxDescriptionLength, item.ImageAlt),
 	})
 	if err != nil {
-		return errors.Wrap(err, "posting status update")
+		return nil, errors.Wrap(err, "uploading media")
 	}
-
-	level.Info(s.l).Log("msg", "toot successfully sent", "id", status.ID, "url", status.URL)
-	return nil
+	return attachment, nil
 }
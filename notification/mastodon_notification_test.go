@@ -3,8 +3,13 @@ package notification
 import (
 	"context"
 	"flag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/peterbourgon/ff/v3"
 
@@ -28,15 +33,18 @@ func Test_mastodonRepository_Post(t *testing.T) {
 		ff.WithConfigFileParser(ff.PlainParser),
 		ff.WithEnvVarPrefix("WR"),
 	)
+	formatter, err := NewFormatter("mastodon", DefaultTemplate, MastodonLength(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
 	type fields struct {
 		l log.Logger
 		c *mastodon.Client
+		f *Formatter
 	}
 	type args struct {
-		ctx    context.Context
-		text   string
-		author string
-		url    string
+		ctx  context.Context
+		item Item
 	}
 	tests := []struct {
 		name    string
@@ -54,12 +62,15 @@ func Test_mastodonRepository_Post(t *testing.T) {
 					ClientSecret: *mastodonClientSecret,
 					AccessToken:  *mastodonAccessToken,
 				}),
+				f: formatter,
 			},
 			args: args{
-				ctx:    context.TODO(),
-				text:   "Testing something",
-				author: "Philipp",
-				url:    "https://annoying.technology/posts/96c086bc855f1aa8/",
+				ctx: context.TODO(),
+				item: Item{
+					Summary: "Testing something",
+					Author:  "Philipp",
+					Link:    "https://annoying.technology/posts/96c086bc855f1aa8/",
+				},
 			},
 			wantErr: false,
 		},
@@ -69,10 +80,98 @@ func Test_mastodonRepository_Post(t *testing.T) {
 			s := &mastodonRepository{
 				l: tt.fields.l,
 				c: tt.fields.c,
+				f: tt.fields.f,
 			}
-			if err := s.Post(tt.args.ctx, tt.args.text, tt.args.author, tt.args.url); (err != nil) != tt.wantErr {
+			if _, err := s.Post(tt.args.ctx, tt.args.item); (err != nil) != tt.wantErr {
 				t.Errorf("Post() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func Test_mastodonRepository_toot(t *testing.T) {
+	options := MastodonOptions{
+		Visibility:              MastodonPublic,
+		CategoryContentWarnings: map[string]string{"nsfw": "NSFW", "spoilers": "Spoilers"},
+		CategoryVisibilities:    map[string]string{"announcement": MastodonUnlisted},
+	}
+	tests := []struct {
+		name    string
+		options MastodonOptions
+		item    Item
+		want    mastodon.Toot
+	}{
+		{
+			name:    "defaults and language of the item",
+			options: options,
+			item:    Item{Language: "de-DE"},
+			want:    mastodon.Toot{Visibility: MastodonPublic, Language: "de"},
+		},
+		{
+			name:    "category overrides",
+			options: options,
+			item:    Item{Categories: []string{"Go", "NSFW", "Announcement"}},
+			want:    mastodon.Toot{Visibility: MastodonUnlisted, SpoilerText: "NSFW", Sensitive: true},
+		},
+		{
+			name: "configured content warning and language win",
+			options: MastodonOptions{
+				SpoilerText:             "Blog post",
+				Language:                "en",
+				CategoryContentWarnings: map[string]string{"nsfw": "NSFW"},
+			},
+			item: Item{Language: "de", Categories: []string{"nsfw"}},
+			want: mastodon.Toot{SpoilerText: "Blog post", Language: "en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMastodonRepository(log.NewNopLogger(), nil, nil, tt.options)
+			if got := s.toot(tt.item); !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("toot() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mastodonRepository_Schedule(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"The access token is invalid"}`))
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"id":"3221","scheduled_at":"` + form.Get("scheduled_at") + `","params":{}}`))
+	}))
+	defer server.Close()
+
+	formatter, err := NewFormatter("mastodon", "{{ .Title }}", MastodonLength(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := mastodon.NewClient(&mastodon.Config{Server: server.URL, AccessToken: "token"})
+	s := NewMastodonRepository(log.NewNopLogger(), c, formatter, MastodonOptions{Visibility: MastodonUnlisted})
+	at := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	result, err := s.Schedule(context.Background(), Item{Title: "Something annoying", Language: "en"}, at)
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if result.ID != "3221" || !result.PublishedAt.Equal(at) {
+		t.Errorf("Schedule() = %+v, want id 3221 published at %v", result, at)
+	}
+	if form.Get("status") != "Something annoying" || form.Get("visibility") != MastodonUnlisted || form.Get("language") != "en" {
+		t.Errorf("form = %v", form)
+	}
+
+	// The instance rejects statuses scheduled less than 5 minutes in the future
+	result, err = s.Schedule(context.Background(), Item{Title: "Something annoying"}, time.Now())
+	if err != nil {
+		t.Fatalf("Schedule() error = %v", err)
+	}
+	if time.Until(result.PublishedAt) < 5*time.Minute {
+		t.Errorf("Schedule() published at %v, want at least 5 minutes in the future", result.PublishedAt)
+	}
+}
@@ -0,0 +1,141 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+type matrixRepository struct {
+	l             log.Logger
+	c             *http.Client
+	homeserverURL string
+	accessToken   string
+	roomID        string
+	f             *Formatter
+}
+
+// NewMatrixRepository initializes a new Matrix notifier repository sending messages to a room the user of the access
+// token has joined. The room has to be given by its id (!abc:example.org), not by an alias.
+func NewMatrixRepository(l log.Logger, c *http.Client, homeserverURL string, accessToken string, roomID string, f *Formatter) *matrixRepository {
+	return &matrixRepository{
+		l:             l,
+		c:             c,
+		homeserverURL: strings.TrimSuffix(homeserverURL, "/"),
+		accessToken:   accessToken,
+		roomID:        roomID,
+		f:             f,
+	}
+}
+
+func (s *matrixRepository) String() string {
+	return "matrix"
+}
+
+// Whoami returns the Matrix user id the access token belongs to, as reported by /_matrix/client/v3/account/whoami
+func (s *matrixRepository) Whoami(ctx context.Context) (string, error) {
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := s.request(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, &whoami); err != nil {
+		return "", errors.Wrap(err, "getting user information")
+	}
+	return whoami.UserID, nil
+}
+
+func (s *matrixRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	text, err := s.f.Format(item)
+	if err != nil {
+		return nil, err
+	}
+
+	// The homeserver ignores requests with a transaction id it has already seen for this access token, deriving it
+	// from the item makes retries of a post that went through but failed on our side safe.
+	txnID := matrixTransactionID(item.Feed, item.GUID)
+	var sent struct {
+		EventID string `json:"event_id"`
+	}
+	if err := s.request(ctx, http.MethodPut, fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(s.roomID), txnID), map[string]string{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": matrixHTML(text),
+	}, &sent); err != nil {
+		return nil, errors.Wrap(err, "sending message")
+	}
+
+	eventURL := fmt.Sprintf("https://matrix.to/#/%s/%s", url.PathEscape(s.roomID), url.PathEscape(sent.EventID))
+	level.Info(s.l).Log("msg", "matrix message successfully sent", "event_id", sent.EventID, "url", eventURL)
+	return &Result{
+		ID:          sent.EventID,
+		URL:         eventURL,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// request sends a JSON request to the client-server API and decodes the response into v
+func (s *matrixRepository) request(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.homeserverURL+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var matrixErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&matrixErr); err == nil && matrixErr.ErrCode != "" {
+			return errors.Errorf("unexpected status code %d from matrix: %s: %s", resp.StatusCode, matrixErr.ErrCode, matrixErr.Error)
+		}
+		return errors.Errorf("unexpected status code %d from matrix", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// matrixTransactionID derives a stable transaction id from the feed and GUID of an item
+func matrixTransactionID(feed string, guid string) string {
+	sum := sha256.Sum256([]byte(feed + "\n" + guid))
+	return "wr-" + hex.EncodeToString(sum[:16])
+}
+
+// matrixHTML turns the plain text of a message into HTML with clickable links and line breaks
+func matrixHTML(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range urlPattern.FindAllStringIndex(text, -1) {
+		link := text[m[0]:m[1]]
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(link), html.EscapeString(link))
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return strings.ReplaceAll(b.String(), "\n", "<br>")
+}
@@ -0,0 +1,80 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func Test_matrixRepository_Post(t *testing.T) {
+	// The fake homeserver remembers transaction ids like a real one, sending the same one twice doesn't create a new event
+	events := make(map[string]map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed."}`))
+			return
+		}
+		prefix := "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/"
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, prefix) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		txnID := strings.TrimPrefix(r.URL.Path, prefix)
+		if _, ok := events[txnID]; !ok {
+			var content map[string]string
+			json.NewDecoder(r.Body).Decode(&content)
+			events[txnID] = content
+		}
+		w.Write([]byte(`{"event_id":"$event-` + txnID + `"}`))
+	}))
+	defer server.Close()
+
+	formatter, err := NewFormatter("matrix", "{{ .Title }} & more\n{{ .Link }}", Length{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMatrixRepository(log.NewNopLogger(), server.Client(), server.URL, "token", "!room:example.org", formatter)
+	item := Item{
+		GUID:  "https://annoying.technology/posts/1/",
+		Title: "<Something> annoying",
+		Link:  "https://annoying.technology/posts/1/",
+	}
+	first, err := s.Post(context.Background(), item)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	second, err := s.Post(context.Background(), item)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if len(events) != 1 || first.ID != second.ID {
+		t.Errorf("posting the same item twice created %d events (%s, %s), want 1", len(events), first.ID, second.ID)
+	}
+	// Another feed can have an item with the same GUID, it's a different message
+	item.Feed = "podcast"
+	third, err := s.Post(context.Background(), item)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if len(events) != 2 || third.ID == first.ID {
+		t.Errorf("posting the item of another feed created %d events (%s, %s), want 2", len(events), first.ID, third.ID)
+	}
+
+	for _, content := range events {
+		if want := "<Something> annoying & more\nhttps://annoying.technology/posts/1/"; content["body"] != want {
+			t.Errorf("body = %q, want %q", content["body"], want)
+		}
+		if want := `&lt;Something&gt; annoying &amp; more<br><a href="https://annoying.technology/posts/1/">https://annoying.technology/posts/1/</a>`; content["formatted_body"] != want {
+			t.Errorf("formatted_body = %q, want %q", content["formatted_body"], want)
+		}
+		if content["msgtype"] != "m.text" || content["format"] != "org.matrix.custom.html" {
+			t.Errorf("content = %v", content)
+		}
+	}
+}
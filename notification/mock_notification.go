@@ -2,6 +2,8 @@ package notification
 
 import (
 	"context"
+	"time"
+
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 )
@@ -23,7 +25,11 @@ func (s *mockRepository) String() string {
 	return s.name
 }
 
-func (s *mockRepository) Post(ctx context.Context, text string, author string, url string) error {
-	level.Info(s.l).Log("msg", "mocked notification successfully sent", "notification_service", s.String(), "url", "https://example.com/123")
-	return nil
+func (s *mockRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	level.Info(s.l).Log("msg", "mocked notification successfully sent", "notification_service", s.String(), "guid", item.GUID, "image", item.Image, "url", "https://example.com/123")
+	return &Result{
+		ID:          "123",
+		URL:         "https://example.com/123",
+		PublishedAt: time.Now(),
+	}, nil
 }
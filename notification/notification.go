@@ -3,14 +3,48 @@ package notification
 import (
 	"context"
 	"strings"
+	"time"
 )
 
 // Repository is an interface for a notifier repository
 type Repository interface {
-	Post(ctx context.Context, text string, author string, url string) error
+	Post(ctx context.Context, item Item) (*Result, error)
 	String() string
 }
 
+// Scheduler is implemented by notifiers that can schedule posts on the remote service, so they don't depend on us
+// being around at the time they are published
+type Scheduler interface {
+	Schedule(ctx context.Context, item Item, at time.Time) (*Result, error)
+}
+
+// Item is a feed item that should be posted, it's what post templates have access to
+type Item struct {
+	// Feed is the name of the feed the item is from, GUIDs are only unique within a feed
+	Feed       string
+	GUID       string
+	Title      string
+	Summary    string
+	Content    string
+	Author     string
+	Categories []string
+	Link       string
+	Image      string
+	ImageAlt   string
+	Language   string
+	Published  time.Time
+}
+
+// Result describes what was published by a notifier, so we can link, edit or delete it later
+type Result struct {
+	// ID is the id of the post on the remote service
+	ID string
+	// URL is the permalink to the post
+	URL string
+	// PublishedAt is when the remote service published the post
+	PublishedAt time.Time
+}
+
 type Notifiers []Repository
 
 func (n Notifiers) String() string {
@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// slackEscaper escapes the characters that have a special meaning in Slack's mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackLinkEscaper escapes the URL of a link, a | would end the URL and start the text of the link
+var slackLinkEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "|", "%7C")
+
+type slackRepository struct {
+	l          log.Logger
+	c          *http.Client
+	webhookURL string
+	f          *Formatter
+}
+
+// NewSlackRepository initializes a new Slack notifier repository posting Block Kit messages to an incoming webhook. The
+// template renders the text below the linked title, it's escaped for mrkdwn.
+func NewSlackRepository(l log.Logger, c *http.Client, webhookURL string, f *Formatter) *slackRepository {
+	return &slackRepository{
+		l:          l,
+		c:          c,
+		webhookURL: webhookURL,
+		f:          f,
+	}
+}
+
+func (s *slackRepository) String() string {
+	return "slack"
+}
+
+func (s *slackRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	// The title is decoded like the text, so entities in the feed aren't escaped twice
+	title := stripHTML(item.Title)
+	heading := fmt.Sprintf("*<%s|%s>*\n", slackLinkEscaper.Replace(item.Link), slackEscaper.Replace(title))
+	// The heading is part of the same section, the text only gets what's left of the limit
+	length := s.f.length
+	if length.Max > 0 {
+		length.Max -= graphemeCount(heading)
+	}
+	text, err := s.f.WithLength(length).Format(item)
+	if err != nil {
+		return nil, err
+	}
+
+	section := map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{
+			"type": "mrkdwn",
+			// The text is plain text after stripHTML decoded the entities, it's escaped like the title
+			"text": heading + slackEscaper.Replace(text),
+		},
+	}
+	if item.Image != "" {
+		alt := item.ImageAlt
+		if alt == "" {
+			alt = title
+		}
+		section["accessory"] = map[string]interface{}{
+			"type":      "image",
+			"image_url": item.Image,
+			"alt_text":  alt,
+		}
+	}
+	blocks := []interface{}{section}
+	if item.Author != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "context",
+			"elements": []interface{}{
+				map[string]interface{}{
+					"type": "mrkdwn",
+					"text": slackEscaper.Replace(item.Author),
+				},
+			},
+		})
+	}
+	b, err := json.Marshal(map[string]interface{}{
+		// The text is shown in notifications, where blocks aren't rendered
+		"text":   fmt.Sprintf("%s %s", title, item.Link),
+		"blocks": blocks,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "posting slack message")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Incoming webhooks respond with a plain text error code, e.g. "invalid_blocks"
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Errorf("unexpected status code %d from slack: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Incoming webhooks don't tell us anything about the message they created
+	level.Info(s.l).Log("msg", "slack message successfully sent", "guid", item.GUID)
+	return &Result{
+		PublishedAt: time.Now(),
+	}, nil
+}
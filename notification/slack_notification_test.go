@@ -0,0 +1,137 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func Test_slackRepository_Post(t *testing.T) {
+	var message struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+			Accessory struct {
+				ImageURL string `json:"image_url"`
+				AltText  string `json:"alt_text"`
+			} `json:"accessory"`
+			Elements []struct {
+				Text string `json:"text"`
+			} `json:"elements"`
+		} `json:"blocks"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid_payload"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	formatter, err := NewFormatter("slack", MessageTemplate, SlackLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSlackRepository(log.NewNopLogger(), server.Client(), server.URL, formatter)
+	tests := []struct {
+		name     string
+		title    string
+		summary  string
+		link     string
+		imageAlt string
+		want     string
+		wantAlt  string
+	}{
+		{
+			name:    "html",
+			title:   "Apple <3 & more",
+			summary: "<p>A summary</p>",
+			want:    "*<https://annoying.technology/posts/1/|Apple &lt;3 &amp; more>*\nA summary",
+			wantAlt: "Apple <3 & more",
+		},
+		{
+			name:    "entities",
+			title:   "Apple",
+			summary: "<p>Apple &lt;3</p>",
+			want:    "*<https://annoying.technology/posts/1/|Apple>*\nApple &lt;3",
+			wantAlt: "Apple",
+		},
+		{
+			name:    "special characters",
+			title:   "Apple",
+			summary: "<p>a &lt; b &gt; c &amp; &lt;!channel&gt;</p>",
+			want:    "*<https://annoying.technology/posts/1/|Apple>*\na &lt; b &gt; c &amp; &lt;!channel&gt;",
+			wantAlt: "Apple",
+		},
+		{
+			name:    "entities in the title",
+			title:   "A &amp; B",
+			summary: "<p>A summary</p>",
+			want:    "*<https://annoying.technology/posts/1/|A &amp; B>*\nA summary",
+			wantAlt: "A & B",
+		},
+		{
+			// Every & is escaped to five characters, the escaped text has to fit into the section
+			name:    "long text with special characters",
+			title:   "Apple",
+			summary: strings.Repeat("&amp; ", 1000),
+			wantAlt: "Apple",
+		},
+		{
+			name:     "special characters in the link",
+			title:    "Apple",
+			summary:  "<p>A summary</p>",
+			link:     "https://annoying.technology/search?q=a|b&lang=<en>",
+			imageAlt: "A screenshot",
+			want:     "*<https://annoying.technology/search?q=a%7Cb&amp;lang=&lt;en&gt;|Apple>*\nA summary",
+			wantAlt:  "A screenshot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := tt.link
+			if link == "" {
+				link = "https://annoying.technology/posts/1/"
+			}
+			if _, err := s.Post(context.Background(), Item{
+				Title:    tt.title,
+				Summary:  tt.summary,
+				Author:   "Philipp",
+				Link:     link,
+				Image:    "https://annoying.technology/posts/1/image.png",
+				ImageAlt: tt.imageAlt,
+			}); err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+
+			if len(message.Blocks) != 2 {
+				t.Fatalf("blocks = %+v, want section and context", message.Blocks)
+			}
+			if n := graphemeCount(message.Blocks[0].Text.Text); n > slackMaxLength {
+				t.Errorf("section has %d characters, want at most %d", n, slackMaxLength)
+			}
+			if tt.want != "" && message.Blocks[0].Text.Text != tt.want {
+				t.Errorf("section = %q, want %q", message.Blocks[0].Text.Text, tt.want)
+			}
+			if message.Blocks[0].Accessory.ImageURL != "https://annoying.technology/posts/1/image.png" {
+				t.Errorf("section has no thumbnail")
+			}
+			if message.Blocks[0].Accessory.AltText != tt.wantAlt {
+				t.Errorf("alt_text = %q, want %q", message.Blocks[0].Accessory.AltText, tt.wantAlt)
+			}
+			if len(message.Blocks[1].Elements) != 1 || message.Blocks[1].Elements[0].Text != "Philipp" {
+				t.Errorf("context = %+v, want author", message.Blocks[1])
+			}
+		})
+	}
+}
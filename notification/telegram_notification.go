@@ -0,0 +1,184 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// TelegramAPIURL is the base URL of the Telegram Bot API
+const TelegramAPIURL = "https://api.telegram.org"
+
+// TelegramTemplate is the default template for Telegram, which uses the HTML parse mode. Fields are decoded with
+// stripHTML before they are escaped, so entities in the feed aren't escaped twice.
+const TelegramTemplate = "<b>{{ html (stripHTML .Title) }}</b>\n\n{{ html (fill (stripHTML .Summary)) }}\n\n{{ html .Link }}"
+
+const (
+	// telegramMaxRetries is how often a request is retried after hitting the rate limit
+	telegramMaxRetries = 3
+	// telegramMaxRetryAfter is the longest we wait for the rate limit, if it's longer we let the job queue retry later
+	telegramMaxRetryAfter = 60 * time.Second
+)
+
+// TelegramBot is the bot we are posting as
+type TelegramBot struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type telegramRepository struct {
+	l                  log.Logger
+	c                  *http.Client
+	baseURL            string
+	token              string
+	chatID             string
+	f                  *Formatter
+	disableLinkPreview bool
+}
+
+// NewTelegramRepository initializes a new Telegram notifier repository posting to a chat or channel with a bot. The
+// chat id is either numeric or the username of a public channel (@channel). The template has to render Telegram's
+// HTML, items with an image are sent as photo with the text as caption.
+func NewTelegramRepository(l log.Logger, c *http.Client, baseURL string, token string, chatID string, f *Formatter, disableLinkPreview bool) *telegramRepository {
+	return &telegramRepository{
+		l:                  l,
+		c:                  c,
+		baseURL:            strings.TrimSuffix(baseURL, "/"),
+		token:              token,
+		chatID:             chatID,
+		f:                  f,
+		disableLinkPreview: disableLinkPreview,
+	}
+}
+
+func (s *telegramRepository) String() string {
+	return "telegram"
+}
+
+// GetMe returns the id, name and username of the bot the token belongs to, as reported by the getMe method of the Bot API
+func (s *telegramRepository) GetMe(ctx context.Context) (*TelegramBot, error) {
+	var bot TelegramBot
+	if err := s.request(ctx, "getMe", map[string]interface{}{}, &bot); err != nil {
+		return nil, errors.Wrap(err, "getting bot information")
+	}
+	return &bot, nil
+}
+
+type telegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	Chat      struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"chat"`
+}
+
+func (s *telegramRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	var (
+		message telegramMessage
+		sent    bool
+	)
+	if item.Image != "" {
+		// Captions are a lot shorter than messages
+		caption, err := s.f.WithLength(TelegramCaptionLength()).Format(item)
+		if err != nil {
+			return nil, err
+		}
+		err = s.request(ctx, "sendPhoto", map[string]interface{}{
+			"chat_id":    s.chatID,
+			"photo":      item.Image,
+			"caption":    caption,
+			"parse_mode": "HTML",
+		}, &message)
+		if err != nil {
+			// Telegram has to be able to download the image, if it can't we still want the text
+			level.Error(s.l).Log("msg", "error sending photo, sending text only", "image", item.Image, "err", err)
+		}
+		sent = err == nil
+	}
+	if !sent {
+		text, err := s.f.Format(item)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.request(ctx, "sendMessage", map[string]interface{}{
+			"chat_id":    s.chatID,
+			"text":       text,
+			"parse_mode": "HTML",
+			"link_preview_options": map[string]interface{}{
+				"is_disabled": s.disableLinkPreview,
+			},
+		}, &message); err != nil {
+			return nil, errors.Wrap(err, "sending message")
+		}
+	}
+
+	// Only messages in public chats have a link
+	var messageURL string
+	if message.Chat.Username != "" {
+		messageURL = fmt.Sprintf("https://t.me/%s/%d", message.Chat.Username, message.MessageID)
+	}
+	level.Info(s.l).Log("msg", "telegram message successfully sent", "id", message.MessageID, "url", messageURL)
+	return &Result{
+		ID:          strconv.FormatInt(message.MessageID, 10),
+		URL:         messageURL,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// request calls a method of the Bot API and decodes the result into v. If we hit the rate limit, the request is
+// retried after the time Telegram asks us to wait.
+func (s *telegramRepository) request(ctx context.Context, method string, body interface{}, v interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/%s", s.baseURL, s.token, method), bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := s.c.Do(req)
+		if err != nil {
+			// The error contains the url, which contains the token
+			return errors.Errorf("calling %s: %s", method, strings.ReplaceAll(err.Error(), s.token, "<token>"))
+		}
+		var r struct {
+			OK          bool            `json:"ok"`
+			Result      json.RawMessage `json:"result"`
+			ErrorCode   int             `json:"error_code"`
+			Description string          `json:"description"`
+			Parameters  struct {
+				RetryAfter int `json:"retry_after"`
+			} `json:"parameters"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&r)
+		resp.Body.Close()
+		if err != nil {
+			return errors.Errorf("unexpected status code %d from telegram", resp.StatusCode)
+		}
+		if r.OK {
+			return json.Unmarshal(r.Result, v)
+		}
+
+		retryAfter := time.Duration(r.Parameters.RetryAfter) * time.Second
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= telegramMaxRetries || retryAfter > telegramMaxRetryAfter {
+			return errors.Errorf("unexpected status code %d from telegram: %s", resp.StatusCode, r.Description)
+		}
+		level.Warn(s.l).Log("msg", "hit telegram rate limit, retrying", "method", method, "retry_after", retryAfter)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
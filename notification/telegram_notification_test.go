@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+func Test_telegramRepository_Post(t *testing.T) {
+	tests := []struct {
+		name        string
+		item        Item
+		responses   map[string][]string
+		wantMethods []string
+		wantText    string
+		wantErr     bool
+	}{
+		{
+			name: "message",
+			item: Item{Title: "Apple &amp; <em>Google</em> &lt;3", Summary: "A summary", Link: "https://annoying.technology/posts/?id=1&ref=feed"},
+			responses: map[string][]string{
+				"sendMessage": {`{"ok":true,"result":{"message_id":7,"chat":{"id":-100,"username":"annoyingtech"}}}`},
+			},
+			wantMethods: []string{"sendMessage"},
+			wantText:    "<b>Apple &amp; Google &lt;3</b>\n\nA summary\n\nhttps://annoying.technology/posts/?id=1&amp;ref=feed",
+		},
+		{
+			name: "photo",
+			item: Item{Title: "Apple", Link: "https://annoying.technology/posts/1/", Image: "https://annoying.technology/image.png"},
+			responses: map[string][]string{
+				"sendPhoto": {`{"ok":true,"result":{"message_id":7,"chat":{"id":-100,"username":"annoyingtech"}}}`},
+			},
+			wantMethods: []string{"sendPhoto"},
+		},
+		{
+			name: "photo falls back to message",
+			item: Item{Title: "Apple", Link: "https://annoying.technology/posts/1/", Image: "https://annoying.technology/image.png"},
+			responses: map[string][]string{
+				"sendPhoto":   {`{"ok":false,"error_code":400,"description":"Bad Request: wrong file identifier/HTTP URL specified"}`},
+				"sendMessage": {`{"ok":true,"result":{"message_id":7,"chat":{"id":-100,"username":"annoyingtech"}}}`},
+			},
+			wantMethods: []string{"sendPhoto", "sendMessage"},
+		},
+		{
+			name: "retry after rate limit",
+			item: Item{Title: "Apple", Link: "https://annoying.technology/posts/1/"},
+			responses: map[string][]string{
+				"sendMessage": {
+					`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 0","parameters":{"retry_after":0}}`,
+					`{"ok":true,"result":{"message_id":7,"chat":{"id":-100,"username":"annoyingtech"}}}`,
+				},
+			},
+			wantMethods: []string{"sendMessage", "sendMessage"},
+		},
+		{
+			name: "rate limit too long",
+			item: Item{Title: "Apple", Link: "https://annoying.technology/posts/1/"},
+			responses: map[string][]string{
+				"sendMessage": {`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3600","parameters":{"retry_after":3600}}`},
+			},
+			wantMethods: []string{"sendMessage"},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				methods []string
+				text    string
+			)
+			mux := http.NewServeMux()
+			for method, responses := range tt.responses {
+				method, responses := method, responses
+				mux.HandleFunc("/bottoken/"+method, func(w http.ResponseWriter, r *http.Request) {
+					var body map[string]interface{}
+					json.NewDecoder(r.Body).Decode(&body)
+					if body["chat_id"] != "@annoyingtech" || body["parse_mode"] != "HTML" {
+						t.Errorf("%s body = %v", method, body)
+					}
+					if v, ok := body["text"].(string); ok {
+						text = v
+					}
+					response := responses[0]
+					if len(responses) > 1 {
+						responses = responses[1:]
+					}
+					var apiErr struct {
+						ErrorCode int `json:"error_code"`
+					}
+					json.Unmarshal([]byte(response), &apiErr)
+					if apiErr.ErrorCode != 0 {
+						w.WriteHeader(apiErr.ErrorCode)
+					}
+					w.Write([]byte(response))
+					methods = append(methods, method)
+				})
+			}
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			formatter, err := NewFormatter("telegram", TelegramTemplate, TelegramLength())
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewTelegramRepository(log.NewNopLogger(), server.Client(), server.URL, "token", "@annoyingtech", formatter, false)
+			result, err := s.Post(context.Background(), tt.item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(methods) != len(tt.wantMethods) {
+				t.Fatalf("Post() called %v, want %v", methods, tt.wantMethods)
+			}
+			for i := range methods {
+				if methods[i] != tt.wantMethods[i] {
+					t.Errorf("Post() called %v, want %v", methods, tt.wantMethods)
+				}
+			}
+			if tt.wantText != "" && text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if err == nil && result.URL != "https://t.me/annoyingtech/7" {
+				t.Errorf("Post() url = %v", result.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package notification
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTemplate is a quote of the summary, filled up to the length limit of the platform, and the link. The summary
+// goes through stripHTML, which also decodes entities like "&amp;" that feeds often contain.
+const DefaultTemplate = "”{{ fill (stripHTML .Summary) }}“\n\n{{ .Link }}"
+
+// MessageTemplate is the summary of the item, for chat messages that show the title and link on their own
+const MessageTemplate = "{{ fill (stripHTML .Summary) }}"
+
+// Formatter renders a feed item into the text of a post
+type Formatter struct {
+	t      *template.Template
+	length Length
+}
+
+// NewFormatter parses a text/template for posts that have to fit into the given length. Besides the fields of Item
+// the template has access to these helper functions:
+//
+//	fill <text>                     as many whole words as fit into what's left of the length limit
+//	truncateWords <max> <text>      as many whole words as fit into max characters
+//	truncateGraphemes <max> <text>  the first max characters, without breaking up emoji
+//	hashtagify <categories>         "#Category #OtherCategory"
+//	stripHTML <html>                the text content of a HTML snippet
+//	json <value>                    the value encoded as JSON, e.g. for the body of a web hook
+func NewFormatter(name string, text string, length Length) (*Formatter, error) {
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"fill":              func(s string) string { return s },
+		"truncateWords":     truncateWords,
+		"truncateGraphemes": truncateGraphemes,
+		"hashtagify":        hashtagify,
+		"stripHTML":         stripHTML,
+		"json":              toJSON,
+	}).Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s template", name)
+	}
+	return &Formatter{
+		t:      t,
+		length: length,
+	}, nil
+}
+
+// WithLength returns a formatter with the same template for a different length limit, e.g. for image captions
+func (f *Formatter) WithLength(length Length) *Formatter {
+	return &Formatter{
+		t:      f.t,
+		length: length,
+	}
+}
+
+// Format renders the item with the template. If the result is too long, the text passed to "fill" is cut down to as
+// many words as fit.
+func (f *Formatter) Format(item Item) (string, error) {
+	var words int
+	text, err := f.render(item, func(s string) string {
+		if n := len(strings.Fields(s)); n > words {
+			words = n
+		}
+		return s
+	})
+	if err != nil {
+		return "", err
+	}
+	if f.length.Fits(text) {
+		return text, nil
+	}
+
+	// Binary search for the largest number of words that still fit, the length only grows with more words
+	var best string
+	low, high := 0, words-1
+	for low <= high {
+		n := (low + high) / 2
+		candidate, err := f.render(item, func(s string) string {
+			return fill(n, s)
+		})
+		if err != nil {
+			return "", err
+		}
+		if f.length.Fits(candidate) {
+			best = candidate
+			low = n + 1
+		} else {
+			high = n - 1
+		}
+	}
+	if best == "" {
+		return "", errors.Errorf("%s post doesn't fit into %d characters", f.t.Name(), f.length.Max)
+	}
+	return best, nil
+}
+
+func (f *Formatter) render(item Item, fill func(s string) string) (string, error) {
+	t, err := f.t.Clone()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Funcs(template.FuncMap{"fill": fill}).Execute(&b, item); err != nil {
+		return "", errors.Wrapf(err, "executing %s template", f.t.Name())
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// toJSON encodes the value as JSON, including quotes for strings
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// fill returns the first n words of the text, with an ellipsis if words were cut off
+func fill(n int, s string) string {
+	words := strings.Fields(s)
+	if n >= len(words) {
+		return s
+	}
+	if n == 0 {
+		return "..."
+	}
+	return strings.Join(words[:n], " ") + "..."
+}
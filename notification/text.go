@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"strings"
+	"unicode"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// zeroWidthJoiner is used to combine multiple emoji into one, e.g. for family emoji
+const zeroWidthJoiner = '\u200d'
+
+// graphemes splits a string into user-perceived characters. This is an approximation of the Unicode grapheme cluster
+// rules that covers combining marks, emoji modifiers, ZWJ sequences and flags, which is good enough for counting.
+func graphemes(s string) []string {
+	var clusters []string
+	var prev rune
+	var regionalIndicators int
+	start := 0
+	for i, r := range s {
+		if i > 0 && !extendsCluster(prev, r, regionalIndicators) {
+			clusters = append(clusters, s[start:i])
+			start = i
+			regionalIndicators = 0
+		}
+		if isRegionalIndicator(r) {
+			regionalIndicators++
+		}
+		prev = r
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// graphemeCount returns the number of user-perceived characters in a string
+func graphemeCount(s string) int {
+	return len(graphemes(s))
+}
+
+func extendsCluster(prev rune, r rune, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == zeroWidthJoiner || r == zeroWidthJoiner:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	// Emoji skin tone modifiers and tag characters used in subdivision flags
+	case r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		return true
+	// Flags are pairs of regional indicators
+	case isRegionalIndicator(prev) && isRegionalIndicator(r) && regionalIndicators%2 == 1:
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// truncateWords returns as many whole words of the text as fit into max graphemes
+func truncateWords(max int, s string) string {
+	var length int
+	var words []string
+	for _, word := range strings.Fields(s) {
+		wordLength := graphemeCount(word)
+		if len(words) > 0 {
+			// The space between the words
+			wordLength++
+		}
+		if length+wordLength > max {
+			break
+		}
+		length += wordLength
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
+
+// truncateGraphemes cuts the text after max graphemes, without splitting emoji or characters with combining marks
+func truncateGraphemes(max int, s string) string {
+	clusters := graphemes(s)
+	if len(clusters) <= max {
+		return s
+	}
+	return strings.Join(clusters[:max], "")
+}
+
+// hashtagify turns categories into hashtags, "web development" and "go" become "#WebDevelopment #Go"
+func hashtagify(categories []string) string {
+	var tags []string
+	for _, category := range categories {
+		var tag strings.Builder
+		for _, word := range strings.FieldsFunc(category, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}) {
+			runes := []rune(word)
+			tag.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+		}
+		if tag.Len() > 0 {
+			tags = append(tags, "#"+tag.String())
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// stripHTML removes all tags from an HTML snippet and returns the unescaped text. Everything but inline elements is
+// replaced by a space, so words don't run into each other.
+func stripHTML(s string) string {
+	var text strings.Builder
+	z := nethtml.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return strings.Join(strings.Fields(text.String()), " ")
+		case nethtml.TextToken:
+			text.Write(z.Text())
+		case nethtml.StartTagToken, nethtml.EndTagToken, nethtml.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "a", "abbr", "b", "code", "em", "i", "mark", "small", "span", "strong", "sub", "sup", "u":
+			default:
+				text.WriteString(" ")
+			}
+		}
+	}
+}
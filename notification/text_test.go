@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"testing"
+)
+
+func Test_graphemeCount(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "ascii", s: "hello", want: 5},
+		{name: "umlauts", s: "Grüße", want: 5},
+		{name: "combining mark", s: "é", want: 1},
+		{name: "emoji with skin tone", s: "👍🏽", want: 1},
+		{name: "zwj sequence", s: "👩‍👩‍👧", want: 1},
+		{name: "flags", s: "🇩🇪🇫🇷", want: 2},
+		{name: "crlf", s: "a\r\nb", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := graphemeCount(tt.s); got != tt.want {
+				t.Errorf("graphemeCount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_truncateWords(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		s    string
+		want string
+	}{
+		{name: "fits", max: 20, s: "short text", want: "short text"},
+		{name: "cut at word boundary", max: 12, s: "this is a longer text", want: "this is a"},
+		{name: "exact fit", max: 9, s: "this is a longer text", want: "this is a"},
+		{name: "first word too long", max: 3, s: "longer text", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateWords(tt.max, tt.s); got != tt.want {
+				t.Errorf("truncateWords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTwitterLength(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{name: "latin", s: "Hello World", want: 11},
+		{name: "link", s: "Read https://example.com/a/very/long/link/to/something", want: 28},
+		{name: "cjk", s: "日本語", want: 6},
+		{name: "emoji sequence", s: "👩‍👩‍👧!", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TwitterLength().Count(tt.s); got != tt.want {
+				t.Errorf("Count() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_hashtagify(t *testing.T) {
+	if got, want := hashtagify([]string{"go", "web development", "C++", "--"}), "#Go #WebDevelopment #C"; got != want {
+		t.Errorf("hashtagify() = %q, want %q", got, want)
+	}
+}
+
+func Test_stripHTML(t *testing.T) {
+	if got, want := stripHTML(`<p>Hello <strong>World</strong> &amp; friends</p><p>Next&nbsp;paragraph</p>`), "Hello World & friends Next paragraph"; got != want {
+		t.Errorf("stripHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatter_Format(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		length   Length
+		item     Item
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default template",
+			template: DefaultTemplate,
+			length:   TwitterLength(),
+			item:     Item{Summary: "Testing something", Link: "https://example.com/1"},
+			want:     "”Testing something“\n\nhttps://example.com/1",
+		},
+		{
+			name:     "fill up to the limit",
+			template: DefaultTemplate,
+			length:   Length{Max: 25, Count: graphemeCount},
+			item:     Item{Summary: "one two three four five six", Link: "https://x.y"},
+			want:     "”one two...“\n\nhttps://x.y",
+		},
+		{
+			name:     "links count as 23 characters on mastodon",
+			template: DefaultTemplate,
+			length:   MastodonLength(40, 0),
+			item:     Item{Summary: "one two three four five six", Link: "https://example.com/a/very/long/link/that/would/not/fit"},
+			want:     "”one two...“\n\nhttps://example.com/a/very/long/link/that/would/not/fit",
+		},
+		{
+			name:     "doesn't fit without fill",
+			template: `{{ .Summary }}`,
+			length:   Length{Max: 5, Count: graphemeCount},
+			item:     Item{Summary: "one two three"},
+			wantErr:  true,
+		},
+		{
+			name:     "custom template",
+			template: `{{ .Title }} by {{ .Author }} {{ hashtagify .Categories }} {{ .Link }}`,
+			length:   BlueskyLength(),
+			item:     Item{Title: "Post", Author: "Philipp", Categories: []string{"go"}, Link: "https://example.com/1"},
+			want:     "Post by Philipp #Go https://example.com/1",
+		},
+		{
+			name:     "entities are decoded before counting",
+			template: DefaultTemplate,
+			length:   Length{Max: 28, Count: graphemeCount},
+			item:     Item{Summary: "Tom &amp; Jerry&#39;s", Link: "https://x.y"},
+			want:     "”Tom & Jerry's“\n\nhttps://x.y",
+		},
+		{
+			name:     "double encoded entities are decoded once",
+			template: `{{ stripHTML .Title }}`,
+			item:     Item{Title: "&amp;lt;script&amp;gt;"},
+			want:     "&lt;script&gt;",
+		},
+		{
+			name:     "unknown field",
+			template: `{{ .Description }}`,
+			item:     Item{},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFormatter("test", tt.template, tt.length)
+			if err != nil {
+				t.Fatalf("NewFormatter() error = %v", err)
+			}
+			got, err := f.Format(tt.item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Format() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
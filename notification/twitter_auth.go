@@ -0,0 +1,136 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/oauth1"
+	"github.com/pkg/errors"
+)
+
+// NewTwitterOAuth1Client returns a client that signs requests with OAuth 1.0a user context, using the keys and tokens
+// of an app from the developer portal
+func NewTwitterOAuth1Client(consumerKey string, consumerSecret string, accessToken string, accessTokenSecret string) *http.Client {
+	config := oauth1.NewConfig(consumerKey, consumerSecret)
+	token := oauth1.NewToken(accessToken, accessTokenSecret)
+	return config.Client(oauth1.NoContext, token)
+}
+
+// TwitterToken is an OAuth 2.0 user token. Refresh tokens can only be used once, so the token is stored in a file every
+// time it's refreshed.
+type TwitterToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type twitterOAuth2Transport struct {
+	mu           sync.Mutex
+	base         http.RoundTripper
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	tokenFile    string
+	token        TwitterToken
+}
+
+// NewTwitterOAuth2Client returns a client that authenticates requests with an OAuth 2.0 user token, obtained with the
+// authorization code flow with PKCE. The access token is refreshed when it expires and the new token is stored in
+// tokenFile. If the file doesn't exist yet the refresh token is used to get the first access token. The client secret
+// is only needed for confidential clients.
+func NewTwitterOAuth2Client(baseURL string, clientID string, clientSecret string, tokenFile string, refreshToken string) (*http.Client, error) {
+	t := &twitterOAuth2Transport{
+		base:         http.DefaultTransport,
+		tokenURL:     strings.TrimSuffix(baseURL, "/") + "/2/oauth2/token",
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenFile:    tokenFile,
+		token: TwitterToken{
+			RefreshToken: refreshToken,
+		},
+	}
+	b, err := os.ReadFile(tokenFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading token file")
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, &t.token); err != nil {
+			return nil, errors.Wrap(err, "decoding token file")
+		}
+	}
+	if t.token.RefreshToken == "" {
+		return nil, errors.New("no twitter refresh token configured")
+	}
+	return &http.Client{
+		Transport: t,
+	}, nil
+}
+
+func (t *twitterOAuth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	accessToken, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+	return t.base.RoundTrip(r)
+}
+
+// accessToken returns a valid access token, refreshing it if it's about to expire
+func (t *twitterOAuth2Transport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.AccessToken != "" && time.Now().Add(time.Minute).Before(t.token.ExpiresAt) {
+		return t.token.AccessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", t.token.RefreshToken)
+	form.Set("client_id", t.clientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if t.clientSecret != "" {
+		req.SetBasicAuth(t.clientID, t.clientSecret)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return "", errors.Wrap(err, "refreshing twitter token")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unexpected status code %d refreshing twitter token", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", errors.Wrap(err, "decoding twitter token")
+	}
+	t.token.AccessToken = token.AccessToken
+	t.token.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	// Refresh tokens are rotated, the old one is invalid now
+	if token.RefreshToken != "" {
+		t.token.RefreshToken = token.RefreshToken
+	}
+
+	b, err := json.Marshal(t.token)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(t.tokenFile, b, 0600); err != nil {
+		return "", errors.Wrap(err, "writing token file")
+	}
+	return t.token.AccessToken, nil
+}
@@ -3,29 +3,49 @@ package notification
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"html"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
+	"time"
 
-	"github.com/dghubble/go-twitter/twitter"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 )
 
+// TwitterAPIURL is the base URL of the X API
+const TwitterAPIURL = "https://api.twitter.com"
+
+// twitterMaxAltTextLength is the maximum length of the alt text of an image
+const twitterMaxAltTextLength = 1000
+
+// TwitterUser is the account we are posting as
+type TwitterUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
 type twitterRepository struct {
-	l  log.Logger
-	c  *twitter.Client
-	tu *twitter.User
+	l       log.Logger
+	c       *http.Client
+	baseURL string
+	tu      *TwitterUser
+	f       *Formatter
 }
 
-// NewTwitterRepository initializes a new Twitter notifier repository
-func NewTwitterRepository(l log.Logger, c *twitter.Client, tu *twitter.User) *twitterRepository {
+// NewTwitterRepository initializes a new Twitter notifier repository using the X API v2. The client has to take care of
+// authentication, see NewTwitterOAuth1Client and NewTwitterOAuth2Client.
+func NewTwitterRepository(l log.Logger, c *http.Client, baseURL string, tu *TwitterUser, f *Formatter) *twitterRepository {
 	return &twitterRepository{
-		l:  l,
-		c:  c,
-		tu: tu,
+		l:       l,
+		c:       c,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		tu:      tu,
+		f:       f,
 	}
 }
 
@@ -33,38 +53,142 @@ func (s *twitterRepository) String() string {
 	return "twitter"
 }
 
-func (s *twitterRepository) Post(ctx context.Context, text string, author string, url string) error {
-	// We split into words, and add as many words while trying to stay roughly under 100 characters for the tweet
-	var length int
-	var summaryTokens []string
-	for _, t := range strings.Split(text, " ") {
-		runes := bytes.Runes([]byte(t))
-		// If length of tweet is below 100, we add tokens to the list
-		if length <= 100 {
-			length += len(runes)
-			summaryTokens = append(summaryTokens, t)
+func (s *twitterRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	text, err := s.f.Format(item)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{
+		"text": text,
+	}
+	if item.Image != "" {
+		mediaID, err := s.uploadImage(ctx, item)
+		if err != nil {
+			level.Error(s.l).Log("msg", "error uploading image, posting tweet without it", "image", item.Image, "err", err)
 		} else {
-			// Only if the word is shorter than 5 do we also still add it to the list
-			if len(runes) < 5 {
-				summaryTokens = append(summaryTokens, t)
-				length += len(runes)
-				break
-			} else {
-				break
+			body["media"] = map[string]interface{}{
+				"media_ids": []string{mediaID},
 			}
 		}
 	}
-	tweetBody := html.UnescapeString("”" + strings.Join(summaryTokens, " ") + "...“" + "\n\n" + url)
-	t, resp, err := s.c.Statuses.Update(tweetBody, &twitter.StatusUpdateParams{
-		TweetMode: "extended",
-	})
+	var tweet struct {
+		Data struct {
+			ID   string `json:"id"`
+			Text string `json:"text"`
+		} `json:"data"`
+	}
+	if err := twitterRequest(ctx, s.c, http.MethodPost, s.baseURL+"/2/tweets", body, &tweet); err != nil {
+		return nil, errors.Wrap(err, "posting tweet")
+	}
+
+	tweetURL := fmt.Sprintf("https://twitter.com/%s/status/%s", s.tu.Username, tweet.Data.ID)
+	level.Info(s.l).Log("msg", "tweet successfully sent", "id", tweet.Data.ID, "url", tweetURL)
+	return &Result{
+		ID:          tweet.Data.ID,
+		URL:         tweetURL,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// uploadImage downloads the image of the item and uploads it with its alt text, it returns the id of the media
+func (s *twitterRepository) uploadImage(ctx context.Context, item Item) (string, error) {
+	img, err := downloadImage(ctx, item.Image, twitterImageLimits)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	mw.WriteField("media_category", "tweet_image")
+	mw.WriteField("media_type", img.contentType)
+	fw, err := mw.CreateFormFile("media", "image")
+	if err != nil {
+		return "", err
+	}
+	fw.Write(img.data)
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/2/media/upload", &b)
 	if err != nil {
-		return errors.Wrap(err, "posting status update")
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var media struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
 	}
-	if resp.StatusCode != http.StatusOK {
-		level.Error(s.l).Log("err", "unexpected status code from twitter", "status_code", resp.StatusCode)
-		return errors.New("unexpected status code from twitter")
+	if err := twitterDo(s.c, req, &media); err != nil {
+		return "", errors.Wrap(err, "uploading media")
+	}
+
+	if item.ImageAlt != "" {
+		if err := twitterRequest(ctx, s.c, http.MethodPost, s.baseURL+"/2/media/metadata", map[string]interface{}{
+			"id": media.Data.ID,
+			"metadata": map[string]interface{}{
+				"alt_text": map[string]string{
+					"text": truncateGraphemes(twitterMaxAltTextLength, item.ImageAlt),
+				},
+			},
+		}, &struct{}{}); err != nil {
+			// The image is still better than nothing
+			level.Error(s.l).Log("msg", "error adding alt text to image", "media_id", media.Data.ID, "err", err)
+		}
+	}
+	return media.Data.ID, nil
+}
+
+// FetchTwitterUser returns the id and username of the account the client is authenticated as from GET /2/users/me. It's
+// called once on startup so wrong credentials fail right away instead of on the first post.
+func FetchTwitterUser(ctx context.Context, c *http.Client, baseURL string) (*TwitterUser, error) {
+	var me struct {
+		Data TwitterUser `json:"data"`
+	}
+	if err := twitterRequest(ctx, c, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/2/users/me", nil, &me); err != nil {
+		return nil, errors.Wrap(err, "getting user information")
+	}
+	return &me.Data, nil
+}
+
+// twitterRequest sends a JSON request to the X API and decodes the response into v
+func twitterRequest(ctx context.Context, c *http.Client, method string, url string, body interface{}, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return twitterDo(c, req, v)
+}
+
+// twitterDo sends the request to the X API and decodes the response into v
+func twitterDo(c *http.Client, req *http.Request, v interface{}) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Errors are returned as problem details, e.g. when the access level of the app isn't enough
+		var problem struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err == nil && problem.Detail != "" {
+			return errors.Errorf("unexpected status code %d from twitter: %s: %s", resp.StatusCode, problem.Title, problem.Detail)
+		}
+		return errors.Errorf("unexpected status code %d from twitter", resp.StatusCode)
 	}
-	level.Info(s.l).Log("msg", "tweet successfully sent", "id", t.IDStr, "url", fmt.Sprintf("https://twitter.com/%s/status/%s", s.tu.ScreenName, t.IDStr))
-	return nil
+	return json.NewDecoder(resp.Body).Decode(v)
 }
@@ -0,0 +1,163 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+)
+
+// newFakeTwitter starts a fake X API that accepts requests with the given authorization prefix
+func newFakeTwitter(t *testing.T, authPrefix string) (*httptest.Server, *[]string) {
+	var tweets []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token_type":"bearer","expires_in":7200,"access_token":"access-2","refresh_token":"refresh-2"}`))
+	})
+	mux.HandleFunc("/2/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), authPrefix) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"title":"Unauthorized","detail":"Unauthorized","status":401}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"id":"2244994945","name":"Annoying Technology","username":"annoyingtech"}}`))
+	})
+	mux.HandleFunc("/2/tweets", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), authPrefix) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Text  string `json:"text"`
+			Media struct {
+				MediaIDs []string `json:"media_ids"`
+			} `json:"media"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tweets = append(tweets, strings.TrimSpace(body.Text+" "+strings.Join(body.Media.MediaIDs, " ")))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"data":{"id":"1445880548472328192","text":"` + body.Text + `"}}`))
+	})
+	mux.HandleFunc("/2/media/upload", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, err := r.FormFile("media"); err != nil || r.FormValue("media_category") != "tweet_image" || r.FormValue("media_type") != "image/png" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"data":{"id":"1880028106020515840","media_key":"3_1880028106020515840"}}`))
+	})
+	mux.HandleFunc("/2/media/metadata", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"associated_metadata":true}}`))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG\r\n\x1a\n fake image"))
+	})
+	mux.HandleFunc("/image.svg", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s, &tweets
+}
+
+func Test_twitterRepository_Post(t *testing.T) {
+	formatter, err := NewFormatter("twitter", "{{ stripHTML .Title }} {{ .Link }}", TwitterLength())
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := Item{Title: "Testing &amp; something", Link: "https://annoying.technology/posts/96c086bc855f1aa8/"}
+
+	t.Run("oauth 1.0a", func(t *testing.T) {
+		server, tweets := newFakeTwitter(t, "OAuth ")
+		c := NewTwitterOAuth1Client("consumer-key", "consumer-secret", "access-token", "access-token-secret")
+		user, err := FetchTwitterUser(context.Background(), c, server.URL)
+		if err != nil {
+			t.Fatalf("FetchTwitterUser() error = %v", err)
+		}
+		s := NewTwitterRepository(log.NewNopLogger(), c, server.URL, user, formatter)
+		result, err := s.Post(context.Background(), item)
+		if err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		if want := "https://twitter.com/annoyingtech/status/1445880548472328192"; result.URL != want {
+			t.Errorf("Post() url = %v, want %v", result.URL, want)
+		}
+		if want := "Testing & something https://annoying.technology/posts/96c086bc855f1aa8/"; len(*tweets) != 1 || (*tweets)[0] != want {
+			t.Errorf("Post() tweets = %v, want %v", *tweets, want)
+		}
+	})
+
+	t.Run("oauth 2.0 refresh", func(t *testing.T) {
+		server, tweets := newFakeTwitter(t, "Bearer access-2")
+		tokenFile := filepath.Join(t.TempDir(), "token.json")
+		c, err := NewTwitterOAuth2Client(server.URL, "client-id", "", tokenFile, "refresh-1")
+		if err != nil {
+			t.Fatalf("NewTwitterOAuth2Client() error = %v", err)
+		}
+		user, err := FetchTwitterUser(context.Background(), c, server.URL)
+		if err != nil {
+			t.Fatalf("FetchTwitterUser() error = %v", err)
+		}
+		s := NewTwitterRepository(log.NewNopLogger(), c, server.URL, user, formatter)
+		if _, err := s.Post(context.Background(), item); err != nil {
+			t.Fatalf("Post() error = %v", err)
+		}
+		if len(*tweets) != 1 {
+			t.Errorf("Post() tweets = %v, want 1", *tweets)
+		}
+
+		// The rotated refresh token has to be stored, the old one is invalid now
+		b, err := os.ReadFile(tokenFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var token TwitterToken
+		if err := json.Unmarshal(b, &token); err != nil {
+			t.Fatal(err)
+		}
+		if token.RefreshToken != "refresh-2" || token.AccessToken != "access-2" {
+			t.Errorf("stored token = %+v, want refresh-2 and access-2", token)
+		}
+	})
+
+	t.Run("image", func(t *testing.T) {
+		server, tweets := newFakeTwitter(t, "OAuth ")
+		c := NewTwitterOAuth1Client("consumer-key", "consumer-secret", "access-token", "access-token-secret")
+		s := NewTwitterRepository(log.NewNopLogger(), c, server.URL, &TwitterUser{Username: "annoyingtech"}, formatter)
+		for _, image := range []string{"/image.png", "/image.svg"} {
+			item := item
+			item.Image, item.ImageAlt = server.URL+image, "A screenshot"
+			if _, err := s.Post(context.Background(), item); err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+		}
+		// Unsupported images are left out instead of failing the post
+		want := []string{
+			"Testing & something https://annoying.technology/posts/96c086bc855f1aa8/ 1880028106020515840",
+			"Testing & something https://annoying.technology/posts/96c086bc855f1aa8/",
+		}
+		if strings.Join(*tweets, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Post() tweets = %q, want %q", *tweets, want)
+		}
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		server, _ := newFakeTwitter(t, "Bearer something-else")
+		c := NewTwitterOAuth1Client("consumer-key", "consumer-secret", "access-token", "access-token-secret")
+		if _, err := FetchTwitterUser(context.Background(), c, server.URL); err == nil {
+			t.Errorf("FetchTwitterUser() error = nil, want error")
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// webhookRetryBackoff is how long we wait before the first retry, it's doubled for every following one
+const webhookRetryBackoff = 1 * time.Second
+
+// WebhookPayload is the JSON document that is sent if no template is configured
+type WebhookPayload struct {
+	// Feed is the name of the feed the item is from, "default" for the feed of the main settings
+	Feed       string    `json:"feed"`
+	GUID       string    `json:"guid"`
+	Title      string    `json:"title"`
+	Summary    string    `json:"summary"`
+	Content    string    `json:"content"`
+	Author     string    `json:"author"`
+	Categories []string  `json:"categories"`
+	Link       string    `json:"link"`
+	Image      string    `json:"image"`
+	ImageAlt   string    `json:"image_alt"`
+	Language   string    `json:"language"`
+	Published  time.Time `json:"published"`
+}
+
+type webhookRepository struct {
+	l               log.Logger
+	c               *http.Client
+	url             string
+	headers         http.Header
+	secret          []byte
+	signatureHeader string
+	f               *Formatter
+	retries         int
+	backoff         time.Duration
+}
+
+// NewWebhookRepository initializes a new notifier that sends new feed items to a URL. The body is a WebhookPayload, or
+// the rendered template if a formatter is set. If a secret is set the signature header is set to "sha256=" followed by
+// the hex encoded HMAC-SHA256 of the body. Failed requests are retried up to the given number of times.
+func NewWebhookRepository(l log.Logger, c *http.Client, url string, headers http.Header, secret string, signatureHeader string, f *Formatter, retries int) *webhookRepository {
+	return &webhookRepository{
+		l:               l,
+		c:               c,
+		url:             url,
+		headers:         headers,
+		secret:          []byte(secret),
+		signatureHeader: signatureHeader,
+		f:               f,
+		retries:         retries,
+		backoff:         webhookRetryBackoff,
+	}
+}
+
+func (s *webhookRepository) String() string {
+	return "webhook"
+}
+
+func (s *webhookRepository) Post(ctx context.Context, item Item) (*Result, error) {
+	body, err := s.body(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		resp    *webhookResponse
+		backoff = s.backoff
+	)
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		resp, retry, err = s.send(ctx, body)
+		if err == nil || !retry || attempt >= s.retries {
+			break
+		}
+		level.Warn(s.l).Log("msg", "web hook request failed, retrying", "attempt", attempt+1, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "sending web hook")
+	}
+
+	level.Info(s.l).Log("msg", "web hook successfully sent", "guid", item.GUID, "id", resp.ID, "url", resp.URL)
+	return &Result{
+		ID:          resp.ID,
+		URL:         resp.URL,
+		PublishedAt: time.Now(),
+	}, nil
+}
+
+// body renders the request body of the item
+func (s *webhookRepository) body(item Item) ([]byte, error) {
+	if s.f != nil {
+		text, err := s.f.Format(item)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(text), nil
+	}
+	return json.Marshal(WebhookPayload{
+		Feed:       item.Feed,
+		GUID:       item.GUID,
+		Title:      item.Title,
+		Summary:    item.Summary,
+		Content:    item.Content,
+		Author:     item.Author,
+		Categories: item.Categories,
+		Link:       item.Link,
+		Image:      item.Image,
+		ImageAlt:   item.ImageAlt,
+		Language:   item.Language,
+		Published:  item.Published,
+	})
+}
+
+// webhookResponse is what we store as the remote post, if the receiver responds with a JSON object containing it
+type webhookResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// send delivers the body once and reports if it's worth retrying on failure, which is the case for network errors,
+// rate limits and server errors.
+func (s *webhookRepository) send(ctx context.Context, body []byte) (*webhookResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	// Templates can render anything, their content type is set with the configured headers
+	if s.f == nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range s.headers {
+		req.Header[name] = values
+	}
+	if len(s.secret) > 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(body)
+		req.Header.Set(s.signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, errors.Errorf("unexpected status code %d from %s", resp.StatusCode, req.URL.Host)
+	}
+
+	// The response is optional and can be anything, we only keep it if it describes what was created
+	var r webhookResponse
+	_ = json.Unmarshal(b, &r)
+	return &r, false, nil
+}
@@ -0,0 +1,133 @@
+package notification
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+func Test_webhookRepository_Post(t *testing.T) {
+	item := Item{
+		Feed:       "podcast",
+		GUID:       "guid-1",
+		Title:      "Something \"annoying\"",
+		Link:       "https://annoying.technology/posts/1/",
+		Categories: []string{"apple"},
+		Published:  time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name     string
+		template string
+		headers  http.Header
+		statuses []int
+		retries  int
+		wantBody string
+		wantType string
+		requests int
+		wantID   string
+		wantErr  bool
+	}{
+		{
+			name:     "json document",
+			statuses: []int{http.StatusOK},
+			requests: 1,
+			wantBody: `{"feed":"podcast","guid":"guid-1","title":"Something \"annoying\"","summary":"","content":"","author":"","categories":["apple"],"link":"https://annoying.technology/posts/1/","image":"","image_alt":"","language":"","published":"2023-06-01T12:00:00Z"}`,
+			wantType: "application/json",
+			wantID:   "remote-1",
+		},
+		{
+			name:     "template",
+			template: `{"text": {{ json .Title }}}`,
+			statuses: []int{http.StatusOK},
+			requests: 1,
+			wantBody: `{"text": "Something \"annoying\""}`,
+			wantID:   "remote-1",
+		},
+		{
+			name:     "template with content type",
+			template: `{{ .Title }}`,
+			headers:  http.Header{"Content-Type": {"text/plain"}},
+			statuses: []int{http.StatusOK},
+			requests: 1,
+			wantBody: `Something "annoying"`,
+			wantType: "text/plain",
+			wantID:   "remote-1",
+		},
+		{
+			name:     "retry server errors",
+			statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK},
+			retries:  2,
+			requests: 3,
+			wantID:   "remote-1",
+		},
+		{
+			name:     "give up after retries",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway},
+			retries:  1,
+			requests: 2,
+			wantErr:  true,
+		},
+		{
+			name:     "don't retry client errors",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			retries:  1,
+			requests: 1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mac := hmac.New(sha256.New, []byte("secret"))
+				mac.Write(body)
+				if r.Header.Get("X-Signature-256") != "sha256="+hex.EncodeToString(mac.Sum(nil)) || r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("request isn't signed or misses headers: %v", r.Header)
+				}
+				if tt.wantBody != "" && string(body) != tt.wantBody {
+					t.Errorf("body = %s, want %s", body, tt.wantBody)
+				}
+				if tt.wantBody != "" && r.Header.Get("Content-Type") != tt.wantType {
+					t.Errorf("Content-Type = %q, want %q", r.Header.Get("Content-Type"), tt.wantType)
+				}
+				w.WriteHeader(tt.statuses[requests])
+				w.Write([]byte(`{"id":"remote-1"}`))
+				requests++
+			}))
+			defer server.Close()
+
+			var f *Formatter
+			if tt.template != "" {
+				var err error
+				if f, err = NewFormatter("webhook", tt.template, Length{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			headers := http.Header{"Authorization": {"Bearer token"}}
+			for name, values := range tt.headers {
+				headers[name] = values
+			}
+			s := NewWebhookRepository(log.NewNopLogger(), server.Client(), server.URL, headers, "secret", "X-Signature-256", f, tt.retries)
+			s.backoff = time.Millisecond
+			result, err := s.Post(context.Background(), item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if requests != tt.requests {
+				t.Errorf("Post() sent %d requests, want %d", requests, tt.requests)
+			}
+			if err == nil && result.ID != tt.wantID {
+				t.Errorf("Post() id = %v, want %v", result.ID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package queue
+
+import "time"
+
+const (
+	// StateQueued is a job waiting to be picked up by a worker once its run_at is reached
+	StateQueued = "queued"
+	// StateRunning is a job currently processed by a worker
+	StateRunning = "running"
+	// StateDone is a successfully processed job
+	StateDone = "done"
+	// StateFailed is a job that ran out of attempts
+	StateFailed = "failed"
+)
+
+// KindProcessFeed is a job that fetches a feed and posts the next uncached items to its notifiers
+const KindProcessFeed = "process_feed"
+
+// Repository is an interface for a persistent job queue
+type Repository interface {
+	Enqueue(kind string, payload string, runAt time.Time) (int64, error)
+	Scheduled(kind string, payload string, runAt time.Time) (bool, error)
+	Claim(now time.Time) (*Job, bool, error)
+	Complete(id int64) error
+	Retry(id int64, jobErr error, runAt time.Time) error
+	Fail(id int64, jobErr error) error
+	Recover() (int64, error)
+	Prune(before time.Time) (int64, error)
+}
+
+// Job is a struct for a queued job
+type Job struct {
+	ID        int64     `db:"id"`
+	Kind      string    `db:"kind"`
+	Payload   string    `db:"payload"`
+	State     string    `db:"state"`
+	Attempts  int       `db:"attempts"`
+	LastError string    `db:"last_error"`
+	RunAt     time.Time `db:"run_at"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/dewey/webhook-receiver/database"
+	"github.com/go-kit/log"
+	"github.com/jmoiron/sqlx"
+)
+
+type repository struct {
+	l  log.Logger
+	db *sqlx.DB
+}
+
+// NewRepository initializes a new job queue repository
+func NewRepository(l log.Logger, db *sqlx.DB) (*repository, error) {
+	return &repository{
+		l:  l,
+		db: db,
+	}, nil
+}
+
+// Enqueue adds a new job to the queue that will be picked up once runAt is reached
+func (s *repository) Enqueue(kind string, payload string, runAt time.Time) (int64, error) {
+	now := database.FormatTime(time.Now())
+	res, err := s.db.NamedExec("INSERT INTO jobs (kind, payload, state, attempts, last_error, run_at, created_at, updated_at) VALUES (:kind, :payload, :state, 0, '', :run_at, :now, :now)",
+		map[string]interface{}{
+			"kind":    kind,
+			"payload": payload,
+			"state":   StateQueued,
+			"run_at":  database.FormatTime(runAt),
+			"now":     now,
+		})
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// Scheduled checks if a queued job of the kind and with the payload is going to run at or before runAt, so we don't
+// queue the same work twice
+func (s *repository) Scheduled(kind string, payload string, runAt time.Time) (bool, error) {
+	var count int
+	if err := s.db.Get(&count, "SELECT COUNT(*) FROM jobs WHERE kind=$1 AND payload=$2 AND state=$3 AND run_at<=$4", kind, payload, StateQueued, database.FormatTime(runAt)); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Claim returns the next due job and marks it as running, so no other worker picks it up
+func (s *repository) Claim(now time.Time) (*Job, bool, error) {
+	var job Job
+	err := s.db.Get(&job, `UPDATE jobs SET state=$1, attempts=attempts+1, updated_at=$2
+		WHERE id = (SELECT id FROM jobs WHERE state=$3 AND run_at <= $2 ORDER BY run_at, id LIMIT 1)
+		RETURNING *`, StateRunning, database.FormatTime(now), StateQueued)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return &job, true, nil
+}
+
+// Complete marks a job as successfully processed
+func (s *repository) Complete(id int64) error {
+	_, err := s.db.Exec("UPDATE jobs SET state=$1, last_error='', updated_at=$2 WHERE id=$3", StateDone, database.FormatTime(time.Now()), id)
+	return err
+}
+
+// Retry puts a job back into the queue to be picked up again once runAt is reached
+func (s *repository) Retry(id int64, jobErr error, runAt time.Time) error {
+	_, err := s.db.Exec("UPDATE jobs SET state=$1, last_error=$2, run_at=$3, updated_at=$4 WHERE id=$5", StateQueued, jobErr.Error(), database.FormatTime(runAt), database.FormatTime(time.Now()), id)
+	return err
+}
+
+// Fail marks a job as failed, it will not be picked up again
+func (s *repository) Fail(id int64, jobErr error) error {
+	_, err := s.db.Exec("UPDATE jobs SET state=$1, last_error=$2, updated_at=$3 WHERE id=$4", StateFailed, jobErr.Error(), database.FormatTime(time.Now()), id)
+	return err
+}
+
+// Recover puts jobs that were still running when the process stopped back into the queue and returns how many there were
+func (s *repository) Recover() (int64, error) {
+	res, err := s.db.Exec("UPDATE jobs SET state=$1, updated_at=$2 WHERE state=$3", StateQueued, database.FormatTime(time.Now()), StateRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Prune deletes done and failed jobs that finished before the given time and returns how many there were
+func (s *repository) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM jobs WHERE state IN ($1, $2) AND updated_at < $3", StateDone, StateFailed, database.FormatTime(before))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,156 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dewey/webhook-receiver/database/databasetest"
+	"github.com/go-kit/log"
+)
+
+// newTestRepository returns a repository on a new database, migrated with the migrations of the api
+func newTestRepository(t *testing.T) *repository {
+	t.Helper()
+	r, err := NewRepository(log.NewNopLogger(), databasetest.NewDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func Test_repository_Claim(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	later, err := r.Enqueue(KindProcessFeed, "later", now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := r.Enqueue(KindProcessFeed, "due", now.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, err := r.Claim(now)
+	if err != nil || !ok {
+		t.Fatalf("Claim() = %v, %v, want the due job", ok, err)
+	}
+	if job.ID != due || job.State != StateRunning || job.Attempts != 1 {
+		t.Errorf("Claim() = %+v, want job %d running in its first attempt", job, due)
+	}
+	// The running job can't be claimed twice and the other one isn't due yet
+	if _, ok, err := r.Claim(now); err != nil || ok {
+		t.Errorf("Claim() = %v, %v, want no job", ok, err)
+	}
+	job, ok, err = r.Claim(now.Add(2 * time.Hour))
+	if err != nil || !ok || job.ID != later {
+		t.Errorf("Claim() = %+v, %v, %v, want job %d", job, ok, err, later)
+	}
+}
+
+func Test_repository_RetryAndFail(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	id, err := r.Enqueue(KindProcessFeed, "{}", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Claim(now); err != nil || !ok {
+		t.Fatalf("Claim() = %v, %v", ok, err)
+	}
+	if err := r.Retry(id, errors.New("instance is down"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Claim(now); ok {
+		t.Error("Claim() returned a job that's retried later")
+	}
+	job, ok, err := r.Claim(now.Add(time.Minute))
+	if err != nil || !ok {
+		t.Fatalf("Claim() = %v, %v, want the retried job", ok, err)
+	}
+	if job.Attempts != 2 || job.LastError != "instance is down" {
+		t.Errorf("Claim() = %+v, want the second attempt with the last error", job)
+	}
+
+	if err := r.Fail(id, errors.New("still down")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Claim(now.Add(time.Hour)); ok {
+		t.Error("Claim() returned a failed job")
+	}
+}
+
+func Test_repository_Recover(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	if _, err := r.Enqueue(KindProcessFeed, "{}", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := r.Claim(now); err != nil || !ok {
+		t.Fatalf("Claim() = %v, %v", ok, err)
+	}
+
+	recovered, err := r.Recover()
+	if err != nil || recovered != 1 {
+		t.Fatalf("Recover() = %d, %v, want 1", recovered, err)
+	}
+	if _, ok, err := r.Claim(now); err != nil || !ok {
+		t.Errorf("Claim() = %v, %v, want the recovered job", ok, err)
+	}
+}
+
+func Test_repository_Scheduled(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	if _, err := r.Enqueue(KindProcessFeed, `{"feed":"blog"}`, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		payload string
+		runAt   time.Time
+		want    bool
+	}{
+		{name: "queued before", payload: `{"feed":"blog"}`, runAt: now.Add(2 * time.Hour), want: true},
+		{name: "queued at the same time", payload: `{"feed":"blog"}`, runAt: now.Add(time.Hour), want: true},
+		{name: "queued after", payload: `{"feed":"blog"}`, runAt: now},
+		{name: "other feed", payload: `{"feed":"podcast"}`, runAt: now.Add(2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Scheduled(KindProcessFeed, tt.payload, tt.runAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Scheduled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_repository_Prune(t *testing.T) {
+	r := newTestRepository(t)
+	now := time.Now()
+	done, _ := r.Enqueue(KindProcessFeed, "done", now)
+	failed, _ := r.Enqueue(KindProcessFeed, "failed", now)
+	if _, err := r.Enqueue(KindProcessFeed, "queued", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Complete(done); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Fail(failed, errors.New("gave up")); err != nil {
+		t.Fatal(err)
+	}
+
+	if pruned, err := r.Prune(now.Add(-time.Hour)); err != nil || pruned != 0 {
+		t.Errorf("Prune() = %d, %v, want nothing pruned before the jobs finished", pruned, err)
+	}
+	if pruned, err := r.Prune(now.Add(time.Hour)); err != nil || pruned != 2 {
+		t.Errorf("Prune() = %d, %v, want the done and failed job pruned", pruned, err)
+	}
+	if _, ok, err := r.Claim(now); err != nil || !ok {
+		t.Errorf("Claim() = %v, %v, want the queued job to be kept", ok, err)
+	}
+}
@@ -0,0 +1,113 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCadence is how often notifiers post if nothing else is configured
+const DefaultCadence = "posts=1;per=day"
+
+// Cadence is how often a notifier posts
+type Cadence struct {
+	// Immediate posts every new item right away, without any limits
+	Immediate bool
+	// Posts is the maximum number of posts within Per, a rolling window
+	Posts int
+	Per   time.Duration
+	// Daily limits Posts per calendar day in the location instead of a rolling window
+	Daily    bool
+	location *time.Location
+	// Gap is the minimum time between two posts
+	Gap time.Duration
+	// Drip works through a backlog of items on its own, by running again once the limits allow the next post
+	Drip bool
+}
+
+// ParseCadence parses a semicolon separated list of options, e.g. "posts=3;per=24h;gap=2h;drip" or "immediate". With
+// "per=day" days start at midnight in the given location.
+func ParseCadence(expr string, loc *time.Location) (*Cadence, error) {
+	c := &Cadence{location: loc}
+	for _, option := range strings.Split(expr, ";") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(option, "=")
+		var err error
+		switch strings.TrimSpace(key) {
+		case "immediate":
+			c.Immediate = true
+		case "drip":
+			c.Drip = true
+		case "posts":
+			if c.Posts, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || c.Posts < 1 {
+				return nil, fmt.Errorf("invalid number of posts %q", value)
+			}
+		case "per":
+			if strings.TrimSpace(value) == "day" {
+				c.Daily = true
+				c.Per = 24 * time.Hour
+				break
+			}
+			if c.Per, err = time.ParseDuration(strings.TrimSpace(value)); err != nil || c.Per <= 0 {
+				return nil, fmt.Errorf("invalid duration %q for per", value)
+			}
+		case "gap":
+			if c.Gap, err = time.ParseDuration(strings.TrimSpace(value)); err != nil || c.Gap <= 0 {
+				return nil, fmt.Errorf("invalid duration %q for gap", value)
+			}
+		default:
+			return nil, fmt.Errorf("unknown cadence option %q, use posts, per, gap, drip or immediate", key)
+		}
+	}
+	if (c.Posts > 0) != (c.Per > 0) {
+		return nil, fmt.Errorf("cadence %q needs both posts and per", expr)
+	}
+	if c.Immediate && (c.Posts > 0 || c.Gap > 0 || c.Drip) {
+		return nil, fmt.Errorf("cadence %q can't limit immediate posts", expr)
+	}
+	if !c.Immediate && c.Posts == 0 && c.Gap == 0 {
+		return nil, fmt.Errorf("cadence %q needs a limit or immediate", expr)
+	}
+	return c, nil
+}
+
+// Since returns when the period that limits the number of posts at t started
+func (c *Cadence) Since(t time.Time) time.Time {
+	if c.Daily {
+		t = t.In(c.location)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.location)
+	}
+	return t.Add(-c.Per)
+}
+
+// Allowed returns how many posts can be published at t, given how many were published since the start of the period
+// before it, when the oldest of those and when the last one was published. If nothing can be published, it also
+// returns when to try again. A negative number means there is no limit.
+func (c *Cadence) Allowed(t time.Time, recent int, oldest time.Time, last time.Time) (int, time.Time) {
+	if c.Immediate {
+		return -1, time.Time{}
+	}
+	allowed := -1
+	if c.Posts > 0 {
+		allowed = c.Posts - recent
+		if allowed <= 0 {
+			if c.Daily {
+				start := c.Since(t)
+				return 0, time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, c.location)
+			}
+			// The window frees up once the oldest post in it leaves
+			return 0, oldest.Add(c.Per)
+		}
+	}
+	if c.Gap > 0 {
+		if !last.IsZero() && t.Sub(last) < c.Gap {
+			return 0, last.Add(c.Gap)
+		}
+		allowed = 1
+	}
+	return allowed, time.Time{}
+}
@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCadence_Allowed(t *testing.T) {
+	now := time.Date(2023, 6, 7, 12, 0, 0, 0, time.UTC)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		expr        string
+		recent      int
+		oldest      time.Time
+		last        time.Time
+		wantAllowed int
+		wantNext    time.Time
+	}{
+		{
+			name:        "immediate",
+			expr:        "immediate",
+			recent:      100,
+			last:        now,
+			wantAllowed: -1,
+		},
+		{
+			name:        "posts left in window",
+			expr:        "posts=3;per=24h",
+			recent:      1,
+			last:        now.Add(-time.Minute),
+			wantAllowed: 2,
+		},
+		{
+			name:        "window is full",
+			expr:        "posts=3;per=24h",
+			recent:      3,
+			oldest:      now.Add(-20 * time.Hour),
+			last:        now.Add(-time.Minute),
+			wantAllowed: 0,
+			wantNext:    now.Add(4 * time.Hour),
+		},
+		{
+			// The next post is allowed when the one from the morning leaves the window, not a day after the check
+			name:        "window frees up with the oldest post",
+			expr:        "posts=1;per=24h",
+			recent:      1,
+			oldest:      time.Date(2023, 6, 7, 10, 0, 0, 0, time.UTC),
+			last:        time.Date(2023, 6, 7, 10, 0, 0, 0, time.UTC),
+			wantAllowed: 0,
+			wantNext:    time.Date(2023, 6, 8, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "gap limits to one post",
+			expr:        "posts=3;per=24h;gap=2h",
+			recent:      1,
+			last:        now.Add(-3 * time.Hour),
+			wantAllowed: 1,
+		},
+		{
+			name:        "too close to the last post",
+			expr:        "gap=2h",
+			recent:      1,
+			last:        now.Add(-30 * time.Minute),
+			wantAllowed: 0,
+			wantNext:    now.Add(90 * time.Minute),
+		},
+		{
+			name:        "day is full",
+			expr:        "posts=1;per=day",
+			recent:      1,
+			last:        now.Add(-time.Hour),
+			wantAllowed: 0,
+			wantNext:    time.Date(2023, 6, 8, 0, 0, 0, 0, berlin),
+		},
+		{
+			name:        "scheduled post in the future",
+			expr:        "gap=2h",
+			last:        now.Add(time.Hour),
+			wantAllowed: 0,
+			wantNext:    now.Add(3 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCadence(tt.expr, berlin)
+			if err != nil {
+				t.Fatalf("ParseCadence() error = %v", err)
+			}
+			allowed, next := c.Allowed(now, tt.recent, tt.oldest, tt.last)
+			if allowed != tt.wantAllowed || !next.Equal(tt.wantNext) {
+				t.Errorf("Allowed() = %d, %v, want %d, %v", allowed, next, tt.wantAllowed, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestParseCadence(t *testing.T) {
+	for _, expr := range []string{"", "posts=3", "per=24h", "posts=0;per=24h", "posts=1;per=week", "gap=soon", "immediate;gap=1h", "sometimes"} {
+		if _, err := ParseCadence(expr, time.UTC); err == nil {
+			t.Errorf("ParseCadence(%q) error = nil, want error", expr)
+		}
+	}
+}
+
+func TestCadence_Since(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2023, 6, 7, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		loc  *time.Location
+		want time.Time
+	}{
+		{expr: "posts=1;per=24h", loc: berlin, want: now.Add(-24 * time.Hour)},
+		{expr: "posts=1;per=day", loc: time.UTC, want: time.Date(2023, 6, 7, 0, 0, 0, 0, time.UTC)},
+		// It's already the next day in Berlin
+		{expr: "posts=1;per=day", loc: berlin, want: time.Date(2023, 6, 8, 0, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.loc.String(), func(t *testing.T) {
+			c, err := ParseCadence(tt.expr, tt.loc)
+			if err != nil {
+				t.Fatalf("ParseCadence() error = %v", err)
+			}
+			if got := c.Since(now); !got.Equal(tt.want) {
+				t.Errorf("Since() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
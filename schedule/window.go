@@ -0,0 +1,160 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a set of times during which posts are published, e.g. on weekdays from 09:00 to 18:00
+type Window struct {
+	rules    []rule
+	location *time.Location
+}
+
+// rule is a time range on some days of the week, start and end are minutes since midnight
+type rule struct {
+	days       [7]bool
+	start, end int
+}
+
+// ParseWindow parses a semicolon separated list of days and time ranges in the given time zone, e.g.
+// "mon-fri 09:00-18:00;sat,sun 10:00-12:00". Without days the time range applies to every day.
+func ParseWindow(expr string, location *time.Location) (*Window, error) {
+	w := &Window{
+		location: location,
+	}
+	for _, part := range strings.Split(expr, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		var (
+			r   rule
+			err error
+		)
+		switch len(fields) {
+		case 1:
+			r.days = [7]bool{true, true, true, true, true, true, true}
+		case 2:
+			if r.days, err = parseDays(fields[0]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("invalid posting window %q, expected days and a time range like \"mon-fri 09:00-18:00\"", part)
+		}
+		if r.start, r.end, err = parseTimeRange(fields[len(fields)-1]); err != nil {
+			return nil, err
+		}
+		w.rules = append(w.rules, r)
+	}
+	if len(w.rules) == 0 {
+		return nil, fmt.Errorf("posting window %q is empty", expr)
+	}
+	return w, nil
+}
+
+// Contains reports if t is within the window
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	minute := t.Hour()*60 + t.Minute()
+	for _, r := range w.rules {
+		if r.days[t.Weekday()] && minute >= r.start && minute < r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns t if it's within the window, otherwise when the window opens next
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	local := t.In(w.location)
+	var next time.Time
+	// Every rule applies at least once a week, so the window opens within the next 7 days
+	for day := 0; day <= 7; day++ {
+		date := local.AddDate(0, 0, day)
+		for _, r := range w.rules {
+			if !r.days[date.Weekday()] {
+				continue
+			}
+			start := time.Date(date.Year(), date.Month(), date.Day(), r.start/60, r.start%60, 0, 0, w.location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return next
+}
+
+// parseDays parses "mon-fri", "sat,sun" or "*"
+func parseDays(s string) ([7]bool, error) {
+	var days [7]bool
+	if s == "*" {
+		return [7]bool{true, true, true, true, true, true, true}, nil
+	}
+	for _, d := range strings.Split(strings.ToLower(s), ",") {
+		from, to, isRange := strings.Cut(d, "-")
+		start, ok := weekdays[from]
+		if !ok {
+			return days, fmt.Errorf("invalid day %q, use mon, tue, wed, thu, fri, sat or sun", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdays[to]; !ok {
+				return days, fmt.Errorf("invalid day %q, use mon, tue, wed, thu, fri, sat or sun", to)
+			}
+		}
+		// Ranges can wrap around the end of the week, e.g. "fri-mon"
+		for wd := start; ; wd = (wd + 1) % 7 {
+			days[wd] = true
+			if wd == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseTimeRange parses "09:00-18:00" into minutes since midnight, "24:00" is allowed as the end of the day
+func parseTimeRange(s string) (int, int, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time range %q, expected something like \"09:00-18:00\"", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("invalid time range %q, the end has to be after the start", s)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil || hour < 0 || minute < 0 || minute > 59 || hour*60+minute > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, expected something like \"09:00\"", s)
+	}
+	return hour*60 + minute, nil
+}
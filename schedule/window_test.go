@@ -0,0 +1,77 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	tests := []struct {
+		name string
+		expr string
+		t    string
+		want string
+	}{
+		{
+			name: "within the window",
+			expr: "mon-fri 09:00-18:00",
+			t:    "2023-06-07T10:30:00+02:00",
+			want: "2023-06-07T10:30:00+02:00",
+		},
+		{
+			name: "before the window opens",
+			expr: "mon-fri 09:00-18:00",
+			t:    "2023-06-07T06:00:00Z",
+			want: "2023-06-07T09:00:00+02:00",
+		},
+		{
+			name: "friday evening",
+			expr: "mon-fri 09:00-18:00",
+			t:    "2023-06-09T18:00:00+02:00",
+			want: "2023-06-12T09:00:00+02:00",
+		},
+		{
+			name: "weekend rule",
+			expr: "mon-fri 09:00-18:00;sat,sun 10:00-12:00",
+			t:    "2023-06-09T20:00:00+02:00",
+			want: "2023-06-10T10:00:00+02:00",
+		},
+		{
+			name: "every day",
+			expr: "12:00-13:00",
+			t:    "2023-06-10T14:00:00+02:00",
+			want: "2023-06-11T12:00:00+02:00",
+		},
+		{
+			name: "daylight saving time",
+			expr: "sun 09:00-10:00",
+			t:    "2023-03-25T12:00:00+01:00",
+			want: "2023-03-26T09:00:00+02:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := ParseWindow(tt.expr, berlin)
+			if err != nil {
+				t.Fatalf("ParseWindow() error = %v", err)
+			}
+			now, _ := time.Parse(time.RFC3339, tt.t)
+			want, _ := time.Parse(time.RFC3339, tt.want)
+			if got := w.Next(now); !got.Equal(want) {
+				t.Errorf("Next() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for _, expr := range []string{"", "mon-fri", "mon-fri 18:00-09:00", "someday 09:00-10:00", "mon 9-10", "mon tue 09:00-10:00"} {
+		if _, err := ParseWindow(expr, time.UTC); err == nil {
+			t.Errorf("ParseWindow(%q) error = nil, want error", expr)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CloudflarePagesWebhookPayload is part of the payload Cloudflare sends us for a Pages project notification
+type CloudflarePagesWebhookPayload struct {
+	AlertType string `json:"alert_type"`
+	Data      struct {
+		Event       string `json:"event"`
+		ProjectName string `json:"project_name"`
+		Environment string `json:"environment"`
+		Branch      string `json:"branch"`
+	} `json:"data"`
+}
+
+type cloudflarePagesProvider struct {
+	filter Filter
+}
+
+// NewCloudflarePagesProvider initializes a new provider for Cloudflare Pages deployment notifications
+func NewCloudflarePagesProvider(filter Filter) *cloudflarePagesProvider {
+	return &cloudflarePagesProvider{
+		filter: filter,
+	}
+}
+
+func (p *cloudflarePagesProvider) String() string {
+	return "cloudflare-pages"
+}
+
+func (p *cloudflarePagesProvider) Decode(r *http.Request) (Event, error) {
+	var payload CloudflarePagesWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return Event{}, errors.Wrap(err, "decoding cloudflare pages payload")
+	}
+	// Cloudflare uses enum-style values like "ENVIRONMENT_PRODUCTION", we normalize them to what the other providers use
+	return Event{
+		Kind:        payload.AlertType,
+		Ref:         payload.Data.Branch,
+		Status:      payload.Data.Event,
+		Environment: strings.ToLower(strings.TrimPrefix(payload.Data.Environment, "ENVIRONMENT_")),
+		Project:     payload.Data.ProjectName,
+	}, nil
+}
+
+// IsActionable checks the event against the configured filter
+func (p *cloudflarePagesProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
+}
@@ -0,0 +1,79 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_cloudflarePagesProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		body string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      args
+		wantEvent Event
+		want      bool
+	}{
+		{
+			name:   "successful production deployment",
+			fields: fields{filter: "kind=pages_event_alert;status=EVENT_DEPLOYMENT_SUCCESS;environment=production"},
+			args: args{
+				body: `{"alert_type":"pages_event_alert","data":{"event":"EVENT_DEPLOYMENT_SUCCESS","project_name":"annoying-technology","environment":"ENVIRONMENT_PRODUCTION","branch":"main"}}`,
+			},
+			wantEvent: Event{Kind: "pages_event_alert", Ref: "main", Status: "EVENT_DEPLOYMENT_SUCCESS", Environment: "production", Project: "annoying-technology"},
+			want:      true,
+		},
+		{
+			name:   "failed production deployment",
+			fields: fields{filter: "kind=pages_event_alert;status=EVENT_DEPLOYMENT_SUCCESS;environment=production"},
+			args: args{
+				body: `{"alert_type":"pages_event_alert","data":{"event":"EVENT_DEPLOYMENT_FAILED","project_name":"annoying-technology","environment":"ENVIRONMENT_PRODUCTION","branch":"main"}}`,
+			},
+			wantEvent: Event{Kind: "pages_event_alert", Ref: "main", Status: "EVENT_DEPLOYMENT_FAILED", Environment: "production", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "successful preview deployment",
+			fields: fields{filter: "kind=pages_event_alert;status=EVENT_DEPLOYMENT_SUCCESS;environment=production"},
+			args: args{
+				body: `{"alert_type":"pages_event_alert","data":{"event":"EVENT_DEPLOYMENT_SUCCESS","project_name":"annoying-technology","environment":"ENVIRONMENT_PREVIEW","branch":"feature"}}`,
+			},
+			wantEvent: Event{Kind: "pages_event_alert", Ref: "feature", Status: "EVENT_DEPLOYMENT_SUCCESS", Environment: "preview", Project: "annoying-technology"},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewCloudflarePagesProvider(filter)
+			r := httptest.NewRequest("POST", "/cloudflare-pages/token", strings.NewReader(tt.args.body))
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if e != tt.wantEvent {
+				t.Errorf("Decode() = %+v, want %+v", e, tt.wantEvent)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_cloudflarePagesProvider_Decode_invalid(t *testing.T) {
+	p := NewCloudflarePagesProvider(Filter{})
+	r := httptest.NewRequest("POST", "/cloudflare-pages/token", strings.NewReader(`{"kind":`))
+	if _, err := p.Decode(r); err == nil {
+		t.Error("Decode() error = nil, want error")
+	}
+}
@@ -0,0 +1,85 @@
+package hooklistener
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Filter decides which events of a provider trigger a post. Every field is a list of glob patterns (as in path.Match),
+// an event matches if every non-empty list has a matching pattern for the corresponding event field.
+type Filter struct {
+	Kinds        []string
+	Refs         []string
+	Statuses     []string
+	Environments []string
+	Projects     []string
+	Workflows    []string
+}
+
+// ParseFilter parses a filter expression like "kind=pipeline;ref=main,release/*;status=success". Clauses are separated
+// by ";" and values by ",". Supported keys are kind, ref, status, environment, project and workflow. An empty value
+// matches events where the field isn't set, an empty expression matches every event.
+func ParseFilter(expr string) (Filter, error) {
+	var f Filter
+	for _, clause := range strings.Split(expr, ";") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(clause, "=")
+		if !ok {
+			return Filter{}, fmt.Errorf("invalid filter clause %q, expected key=value", clause)
+		}
+		var patterns []string
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return Filter{}, errors.Wrapf(err, "invalid pattern %q", pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		switch strings.TrimSpace(key) {
+		case "kind":
+			f.Kinds = append(f.Kinds, patterns...)
+		case "ref":
+			f.Refs = append(f.Refs, patterns...)
+		case "status":
+			f.Statuses = append(f.Statuses, patterns...)
+		case "environment":
+			f.Environments = append(f.Environments, patterns...)
+		case "project":
+			f.Projects = append(f.Projects, patterns...)
+		case "workflow":
+			f.Workflows = append(f.Workflows, patterns...)
+		default:
+			return Filter{}, fmt.Errorf("unknown filter key %q", key)
+		}
+	}
+	return f, nil
+}
+
+// Match checks if the event passes the filter
+func (f Filter) Match(e Event) bool {
+	return matchAny(f.Kinds, e.Kind) &&
+		matchAny(f.Refs, e.Ref) &&
+		matchAny(f.Statuses, e.Status) &&
+		matchAny(f.Environments, e.Environment) &&
+		matchAny(f.Projects, e.Project) &&
+		matchAny(f.Workflows, e.Workflow)
+}
+
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		// Patterns are validated in ParseFilter, so we can ignore the error here
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package hooklistener
+
+import "testing"
+
+func TestFilter_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		event   Event
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:  "empty filter matches everything",
+			expr:  "",
+			event: Event{Kind: "pipeline", Ref: "feature", Status: "failed"},
+			want:  true,
+		},
+		{
+			name:  "matching ref and status",
+			expr:  "kind=pipeline;ref=main,master;status=success",
+			event: Event{Kind: "pipeline", Ref: "master", Status: "success"},
+			want:  true,
+		},
+		{
+			name:  "other ref",
+			expr:  "kind=pipeline;ref=main,master;status=success",
+			event: Event{Kind: "pipeline", Ref: "production", Status: "success"},
+			want:  false,
+		},
+		{
+			name:  "glob ref",
+			expr:  "ref=release/*; status = success",
+			event: Event{Ref: "release/2023-05", Status: "success"},
+			want:  true,
+		},
+		{
+			name:  "glob doesn't cross path separators",
+			expr:  "ref=release/*",
+			event: Event{Ref: "release/2023/05"},
+			want:  false,
+		},
+		{
+			name:  "project path",
+			expr:  "project=dewey/*",
+			event: Event{Project: "someone/blog"},
+			want:  false,
+		},
+		{
+			name:  "empty value matches unset field",
+			expr:  "status=,success",
+			event: Event{},
+			want:  true,
+		},
+		{
+			name:    "unknown key",
+			expr:    "branch=main",
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			expr:    "ref",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			expr:    "ref=[main",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := f.Match(tt.event); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
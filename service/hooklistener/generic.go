@@ -0,0 +1,56 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// GenericWebhookPayload is an optional JSON body for sources we don't have a dedicated provider for, like a cron job
+// or a deploy script calling us with curl. All fields are optional.
+type GenericWebhookPayload struct {
+	Kind        string `json:"kind"`
+	Ref         string `json:"ref"`
+	Status      string `json:"status"`
+	Environment string `json:"environment"`
+	Project     string `json:"project"`
+	Workflow    string `json:"workflow"`
+}
+
+type genericProvider struct {
+	filter Filter
+}
+
+// NewGenericProvider initializes a new provider for generic JSON web hooks
+func NewGenericProvider(filter Filter) *genericProvider {
+	return &genericProvider{
+		filter: filter,
+	}
+}
+
+func (p *genericProvider) String() string {
+	return "generic"
+}
+
+func (p *genericProvider) Decode(r *http.Request) (Event, error) {
+	var payload GenericWebhookPayload
+	// An empty body is fine, it just means "something changed"
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+		return Event{}, errors.Wrap(err, "decoding generic payload")
+	}
+	return Event{
+		Kind:        payload.Kind,
+		Ref:         payload.Ref,
+		Status:      payload.Status,
+		Environment: payload.Environment,
+		Project:     payload.Project,
+		Workflow:    payload.Workflow,
+	}, nil
+}
+
+// IsActionable checks the event against the configured filter
+func (p *genericProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
+}
@@ -0,0 +1,88 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_genericProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		body string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      args
+		wantEvent Event
+		want      bool
+	}{
+		{
+			name:   "empty body",
+			fields: fields{filter: "status=,success"},
+			args: args{
+				body: ``,
+			},
+			wantEvent: Event{},
+			want:      true,
+		},
+		{
+			name:   "successful deploy",
+			fields: fields{filter: "status=,success"},
+			args: args{
+				body: `{"kind":"deploy","ref":"main","status":"success","environment":"production","project":"blog","workflow":"release"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "main", Status: "success", Environment: "production", Project: "blog", Workflow: "release"},
+			want:      true,
+		},
+		{
+			name:   "failed deploy",
+			fields: fields{filter: "status=,success"},
+			args: args{
+				body: `{"kind":"deploy","ref":"main","status":"failed"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "main", Status: "failed"},
+			want:      false,
+		},
+		{
+			name:   "deploy of other project",
+			fields: fields{filter: "project=blog"},
+			args: args{
+				body: `{"project":"docs"}`,
+			},
+			wantEvent: Event{Project: "docs"},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewGenericProvider(filter)
+			r := httptest.NewRequest("POST", "/generic/token", strings.NewReader(tt.args.body))
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if e != tt.wantEvent {
+				t.Errorf("Decode() = %+v, want %+v", e, tt.wantEvent)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_genericProvider_Decode_invalid(t *testing.T) {
+	p := NewGenericProvider(Filter{})
+	r := httptest.NewRequest("POST", "/generic/token", strings.NewReader(`{"kind":`))
+	if _, err := p.Decode(r); err == nil {
+		t.Error("Decode() error = nil, want error")
+	}
+}
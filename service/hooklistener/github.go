@@ -0,0 +1,105 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// GithubWorkflowRunPayload is part of the payload GitHub sends us for a "workflow_run" event
+type GithubWorkflowRunPayload struct {
+	Action      string `json:"action"`
+	WorkflowRun struct {
+		Name       string `json:"name"`
+		HeadBranch string `json:"head_branch"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// GithubDeploymentStatusPayload is part of the payload GitHub sends us for a "deployment_status" event
+type GithubDeploymentStatusPayload struct {
+	DeploymentStatus struct {
+		State       string `json:"state"`
+		Environment string `json:"environment"`
+	} `json:"deployment_status"`
+	Deployment struct {
+		Ref         string `json:"ref"`
+		Task        string `json:"task"`
+		Environment string `json:"environment"`
+	} `json:"deployment"`
+	Workflow struct {
+		Name string `json:"name"`
+	} `json:"workflow"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+type githubProvider struct {
+	filter Filter
+}
+
+// NewGithubProvider initializes a new provider for GitHub Actions "workflow_run" and "deployment_status" events
+func NewGithubProvider(filter Filter) *githubProvider {
+	return &githubProvider{
+		filter: filter,
+	}
+}
+
+func (p *githubProvider) String() string {
+	return "github"
+}
+
+// Decode uses the "X-GitHub-Event" header to figure out which payload we are getting, GitHub sends the same kind of
+// JSON body for all events of a web hook.
+func (p *githubProvider) Decode(r *http.Request) (Event, error) {
+	kind := r.Header.Get("X-GitHub-Event")
+	switch kind {
+	case "workflow_run":
+		var payload GithubWorkflowRunPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			return Event{}, errors.Wrap(err, "decoding github workflow_run payload")
+		}
+		// The conclusion is only set once the workflow run is completed
+		status := payload.WorkflowRun.Status
+		if payload.Action == "completed" {
+			status = payload.WorkflowRun.Conclusion
+		}
+		return Event{
+			Kind:     kind,
+			Ref:      payload.WorkflowRun.HeadBranch,
+			Status:   status,
+			Project:  payload.Repository.FullName,
+			Workflow: payload.WorkflowRun.Name,
+		}, nil
+	case "deployment_status":
+		var payload GithubDeploymentStatusPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			return Event{}, errors.Wrap(err, "decoding github deployment_status payload")
+		}
+		environment := payload.DeploymentStatus.Environment
+		if environment == "" {
+			environment = payload.Deployment.Environment
+		}
+		return Event{
+			Kind:        kind,
+			Ref:         payload.Deployment.Ref,
+			Status:      payload.DeploymentStatus.State,
+			Environment: environment,
+			Project:     payload.Repository.FullName,
+			Workflow:    payload.Workflow.Name,
+		}, nil
+	}
+	// Everything else (e.g. the "ping" event when setting up a hook) is not something we act on
+	return Event{Kind: kind}, nil
+}
+
+// IsActionable checks the event against the configured filter. The kind of the event is the "X-GitHub-Event" header.
+func (p *githubProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
+}
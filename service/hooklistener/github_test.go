@@ -0,0 +1,105 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_githubProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		event string
+		body  string
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		{
+			name:   "successful workflow run on main",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success;ref=main;workflow=deploy"},
+			args: args{
+				event: "workflow_run",
+				body:  `{"action":"completed","workflow_run":{"name":"deploy","head_branch":"main","status":"completed","conclusion":"success"}}`,
+			},
+			want: true,
+		},
+		{
+			name:   "failed workflow run on main",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success;ref=main"},
+			args: args{
+				event: "workflow_run",
+				body:  `{"action":"completed","workflow_run":{"name":"deploy","head_branch":"main","status":"completed","conclusion":"failure"}}`,
+			},
+			want: false,
+		},
+		{
+			name:   "workflow run still in progress",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success;ref=main"},
+			args: args{
+				event: "workflow_run",
+				body:  `{"action":"in_progress","workflow_run":{"name":"deploy","head_branch":"main","status":"in_progress"}}`,
+			},
+			want: false,
+		},
+		{
+			name:   "workflow run of other workflow",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success;ref=main;workflow=deploy"},
+			args: args{
+				event: "workflow_run",
+				body:  `{"action":"completed","workflow_run":{"name":"lint","head_branch":"main","status":"completed","conclusion":"success"}}`,
+			},
+			want: false,
+		},
+		{
+			name:   "successful deployment on other branch",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success;ref=main"},
+			args: args{
+				event: "deployment_status",
+				body:  `{"deployment_status":{"state":"success","environment":"github-pages"},"deployment":{"ref":"feature"}}`,
+			},
+			want: false,
+		},
+		{
+			name:   "successful deployment on any branch",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success"},
+			args: args{
+				event: "deployment_status",
+				body:  `{"deployment_status":{"state":"success","environment":"github-pages"},"deployment":{"ref":"feature"}}`,
+			},
+			want: true,
+		},
+		{
+			name:   "ping event",
+			fields: fields{filter: "kind=workflow_run,deployment_status;status=success"},
+			args: args{
+				event: "ping",
+				body:  `{"zen":"Keep it logically awesome."}`,
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewGithubProvider(filter)
+			r := httptest.NewRequest("POST", "/github/token", strings.NewReader(tt.args.body))
+			r.Header.Set("X-GitHub-Event", tt.args.event)
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
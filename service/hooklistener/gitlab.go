@@ -1,5 +1,12 @@
 package hooklistener
 
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
 // GitlabWebhookPayload is part of the payload GitLab sends us after a pipeline event happened
 type GitlabWebhookPayload struct {
 	ObjectKind       string `json:"object_kind"`
@@ -7,12 +14,40 @@ type GitlabWebhookPayload struct {
 		Ref    string `json:"ref"`
 		Status string `json:"status"`
 	} `json:"object_attributes"`
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+type gitlabProvider struct {
+	filter Filter
 }
 
-// IsActionable checks if the webhook is actionable for us, we filter out other hooks we receive and don't need
-func (p GitlabWebhookPayload) IsActionable() bool {
-	if p.ObjectKind == "pipeline" && p.ObjectAttributes.Ref == "main" && p.ObjectAttributes.Status == "success" {
-		return true
+// NewGitlabProvider initializes a new provider for GitLab pipeline events
+func NewGitlabProvider(filter Filter) *gitlabProvider {
+	return &gitlabProvider{
+		filter: filter,
 	}
-	return false
+}
+
+func (p *gitlabProvider) String() string {
+	return "gitlab"
+}
+
+func (p *gitlabProvider) Decode(r *http.Request) (Event, error) {
+	var payload GitlabWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return Event{}, errors.Wrap(err, "decoding gitlab payload")
+	}
+	return Event{
+		Kind:    payload.ObjectKind,
+		Ref:     payload.ObjectAttributes.Ref,
+		Status:  payload.ObjectAttributes.Status,
+		Project: payload.Project.PathWithNamespace,
+	}, nil
+}
+
+// IsActionable checks the event against the configured filter, GitLab sends us a hook for every pipeline state change
+func (p *gitlabProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
 }
@@ -0,0 +1,97 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_gitlabProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		body string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      args
+		wantEvent Event
+		want      bool
+	}{
+		{
+			name:   "successful pipeline on main",
+			fields: fields{filter: "kind=pipeline;ref=main;status=success"},
+			args: args{
+				body: `{"object_kind":"pipeline","object_attributes":{"ref":"main","status":"success"},"project":{"path_with_namespace":"dewey/annoying.technology"}}`,
+			},
+			wantEvent: Event{Kind: "pipeline", Ref: "main", Status: "success", Project: "dewey/annoying.technology"},
+			want:      true,
+		},
+		{
+			name:   "running pipeline on main",
+			fields: fields{filter: "kind=pipeline;ref=main;status=success"},
+			args: args{
+				body: `{"object_kind":"pipeline","object_attributes":{"ref":"main","status":"running"},"project":{"path_with_namespace":"dewey/annoying.technology"}}`,
+			},
+			wantEvent: Event{Kind: "pipeline", Ref: "main", Status: "running", Project: "dewey/annoying.technology"},
+			want:      false,
+		},
+		{
+			name:   "successful pipeline on other branch",
+			fields: fields{filter: "kind=pipeline;ref=main;status=success"},
+			args: args{
+				body: `{"object_kind":"pipeline","object_attributes":{"ref":"feature","status":"success"},"project":{"path_with_namespace":"dewey/annoying.technology"}}`,
+			},
+			wantEvent: Event{Kind: "pipeline", Ref: "feature", Status: "success", Project: "dewey/annoying.technology"},
+			want:      false,
+		},
+		{
+			name:   "push event",
+			fields: fields{filter: "kind=pipeline;ref=main;status=success"},
+			args: args{
+				body: `{"object_kind":"push","ref":"refs/heads/main","project":{"path_with_namespace":"dewey/annoying.technology"}}`,
+			},
+			wantEvent: Event{Kind: "push", Project: "dewey/annoying.technology"},
+			want:      false,
+		},
+		{
+			name:   "successful pipeline of other project",
+			fields: fields{filter: "kind=pipeline;ref=main;status=success;project=dewey/annoying.technology"},
+			args: args{
+				body: `{"object_kind":"pipeline","object_attributes":{"ref":"main","status":"success"},"project":{"path_with_namespace":"dewey/other"}}`,
+			},
+			wantEvent: Event{Kind: "pipeline", Ref: "main", Status: "success", Project: "dewey/other"},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewGitlabProvider(filter)
+			r := httptest.NewRequest("POST", "/gitlab/token", strings.NewReader(tt.args.body))
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if e != tt.wantEvent {
+				t.Errorf("Decode() = %+v, want %+v", e, tt.wantEvent)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_gitlabProvider_Decode_invalid(t *testing.T) {
+	p := NewGitlabProvider(Filter{})
+	r := httptest.NewRequest("POST", "/gitlab/token", strings.NewReader(`{"kind":`))
+	if _, err := p.Decode(r); err == nil {
+		t.Error("Decode() error = nil, want error")
+	}
+}
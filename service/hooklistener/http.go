@@ -1,8 +1,8 @@
 package hooklistener
 
 import (
-	"encoding/json"
-	"github.com/dewey/webhook-receiver/cache"
+	"bytes"
+	"io"
 	"net/http"
 	"time"
 
@@ -16,7 +16,10 @@ func NewHandler(s service) *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Group(func(r chi.Router) {
-		r.Post("/{uuid}", webHookHandler(s))
+		r.Post("/{provider}/{uuid}", webHookHandler(s))
+		// Providers with a configured signature secret don't need the token in the URL. This is also the legacy route
+		// from before we supported multiple providers, where the only path segment was the token for GitLab.
+		r.Post("/{provider}", webHookHandler(s))
 	})
 
 	return r
@@ -24,75 +27,73 @@ func NewHandler(s service) *chi.Mux {
 
 func webHookHandler(s service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Checking if UUID is in our whitelist, otherwise we can already return early
-		valid, err := s.ValidToken(chi.URLParam(r, "uuid"))
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			level.Error(s.l).Log("err", err)
+		providerName, token := chi.URLParam(r, "provider"), chi.URLParam(r, "uuid")
+		if token == "" {
+			if _, ok := s.providers.Get(providerName); !ok {
+				providerName, token = "gitlab", providerName
+			}
+		}
+		provider, ok := s.providers.Get(providerName)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			level.Debug(s.l).Log("msg", "received hook for provider that is not enabled", "provider", providerName)
 			return
 		}
 
-		var payload GitlabWebhookPayload
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		// We need the raw body to verify signatures, the provider decodes it afterwards
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			level.Error(s.l).Log("err", errors.Wrap(err, "decoding payload"))
+			level.Error(s.l).Log("err", errors.Wrap(err, "reading body"))
 			return
 		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
 
-		// We do nothing if it's just one of many webhooks the pipeline is sending us
-		if !payload.IsActionable() {
-			w.WriteHeader(http.StatusOK)
-			return
+		// If there's a secret configured for the provider the request has to be signed, otherwise we fall back to
+		// checking if the token from the URL belongs to one of our feeds. Signed requests without a token trigger
+		// every feed, with a token only the feed it belongs to.
+		verifier, signed := s.verifiers[provider.String()]
+		if signed {
+			if err := verifier.Verify(r.Header, body); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				level.Info(s.l).Log("msg", "rejected hook with invalid signature", "provider", provider.String(), "err", err)
+				return
+			}
 		}
-
-		// TODO(dewey): This should not be in the handler, but for now it's good enough
-		if valid {
-			level.Info(s.l).Log("msg", "received valid token on webhook endpoint", "uuid", chi.URLParam(r, "uuid"))
-			items, err := s.fr.Entries(s.feedURL)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				level.Error(s.l).Log("err", errors.Wrap(err, "parsing feed"))
+		var feed string
+		if !signed || token != "" {
+			f, ok := s.FeedByToken(token)
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				level.Info(s.l).Log("msg", "rejected hook with invalid token", "provider", provider.String())
 				return
 			}
+			feed = f.Name
+		}
 
-			t := time.Now()
-			for _, notificationService := range s.nr {
-				// If there's already a post for today in the cache for this service, we do nothing.
-				exists, err := s.cr.EntryExists(t, notificationService.String())
-				if err != nil {
-					level.Error(s.l).Log("err", err)
-					continue
-				}
-				if exists {
-					level.Debug(s.l).Log("msg", "there's already a post today, skipping", "notification_service", notificationService.String())
-					continue
-				}
+		event, err := provider.Decode(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			level.Error(s.l).Log("err", err, "provider", provider.String())
+			return
+		}
 
-				item, isCached, err := s.getNextUncachedFeedItem(items, notificationService.String())
-				if err != nil {
-					level.Error(s.l).Log("err", err)
-					continue
-				}
-				if !isCached {
-					level.Info(s.l).Log("msg", "cache miss, send notification", "guid", item.GUID, "notification_service", notificationService.String())
-					if err := s.cr.Set(cache.Entry{
-						Key:                 item.GUID,
-						NotificationService: notificationService.String(),
-						Date:                time.Now().Format("2006-01-02"),
-					}); err != nil {
-						level.Error(s.l).Log("err", err)
-						continue
-					}
-					// If item not in cache yet for this notification service, we can send a notification
-					if err := notificationService.Post(r.Context(), item.Description, item.Author.Name, item.Link); err != nil {
-						level.Error(s.l).Log("err", err)
-						continue
-					}
-				}
-			}
+		// We do nothing if it's just one of many webhooks the pipeline is sending us
+		if !provider.IsActionable(event) {
+			level.Debug(s.l).Log("msg", "ignoring non-actionable hook", "provider", provider.String(), "kind", event.Kind, "ref", event.Ref, "status", event.Status)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-			w.WriteHeader(http.StatusAccepted)
+		// Posting can take a while with slow instances, we queue a job for the workers and return right away, so the
+		// provider doesn't time out and retry the hook.
+		id, err := s.enqueue(feed, &event, time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			level.Error(s.l).Log("err", errors.Wrap(err, "enqueuing job"))
 			return
 		}
+		level.Info(s.l).Log("msg", "received authenticated hook, job queued", "provider", provider.String(), "feed", feed, "project", event.Project, "job_id", id)
+		w.WriteHeader(http.StatusAccepted)
 	}
 }
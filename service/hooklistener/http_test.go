@@ -0,0 +1,79 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-kit/log"
+)
+
+func Test_webHookHandler(t *testing.T) {
+	const gitlabBody = `{"object_kind":"pipeline","object_attributes":{"ref":"main","status":"success"}}`
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		gitlabToken string
+		wantStatus  int
+		// wantFeed is the feed of the queued job, empty if the job processes every feed
+		wantFeed string
+		wantJob  bool
+	}{
+		{name: "token of the default feed", path: "/generic/changeme", wantStatus: http.StatusAccepted, wantFeed: "default", wantJob: true},
+		{name: "token of another feed", path: "/generic/secret", wantStatus: http.StatusAccepted, wantFeed: "podcast", wantJob: true},
+		{name: "unknown token", path: "/generic/wrong", wantStatus: http.StatusUnauthorized},
+		{name: "unsigned without token", path: "/generic", wantStatus: http.StatusUnauthorized},
+		{name: "signed without token", path: "/gitlab", body: gitlabBody, gitlabToken: "gitlab-secret", wantStatus: http.StatusAccepted, wantJob: true},
+		{name: "signed with token", path: "/gitlab/secret", body: gitlabBody, gitlabToken: "gitlab-secret", wantStatus: http.StatusAccepted, wantFeed: "podcast", wantJob: true},
+		{name: "invalid signature", path: "/gitlab/secret", body: gitlabBody, gitlabToken: "wrong", wantStatus: http.StatusUnauthorized},
+		{name: "legacy route", path: "/changeme", body: gitlabBody, gitlabToken: "gitlab-secret", wantStatus: http.StatusAccepted, wantFeed: "default", wantJob: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &enqueueRecorder{}
+			s := NewService(log.NewNopLogger(), nil, []Feed{
+				{Name: "default", HookToken: "changeme"},
+				{Name: "podcast", HookToken: "secret"},
+			}, nil, q, Providers{
+				NewGenericProvider(Filter{}),
+				NewGitlabProvider(Filter{}),
+			}, map[string]Verifier{
+				"gitlab": NewGitlabVerifier("gitlab-secret"),
+			}, PostingOptions{})
+
+			req := httptest.NewRequest(http.MethodPost, "/incoming-hooks"+tt.path, strings.NewReader(tt.body))
+			if tt.gitlabToken != "" {
+				req.Header.Set("X-Gitlab-Token", tt.gitlabToken)
+			}
+			rec := httptest.NewRecorder()
+			r := chi.NewRouter()
+			r.Mount("/incoming-hooks", NewHandler(*s))
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			payloads := q.Payloads()
+			if !tt.wantJob {
+				if len(payloads) != 0 {
+					t.Errorf("queued %v, want no job", payloads)
+				}
+				return
+			}
+			if len(payloads) != 1 {
+				t.Fatalf("queued %v, want a job", payloads)
+			}
+			var payload jobPayload
+			if err := json.Unmarshal([]byte(payloads[0]), &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Feed != tt.wantFeed || payload.Event == nil {
+				t.Errorf("queued %s, want a job with the event for feed %q", payloads[0], tt.wantFeed)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// NetlifyWebhookPayload is part of the payload Netlify sends us after a deploy event happened
+type NetlifyWebhookPayload struct {
+	ID      string `json:"id"`
+	SiteID  string `json:"site_id"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Branch  string `json:"branch"`
+	Context string `json:"context"`
+	URL     string `json:"url"`
+}
+
+type netlifyProvider struct {
+	filter Filter
+}
+
+// NewNetlifyProvider initializes a new provider for Netlify deploy notifications. The deploy context (e.g.
+// "production", "branch-deploy", "deploy-preview") is available as the environment of the event.
+func NewNetlifyProvider(filter Filter) *netlifyProvider {
+	return &netlifyProvider{
+		filter: filter,
+	}
+}
+
+func (p *netlifyProvider) String() string {
+	return "netlify"
+}
+
+func (p *netlifyProvider) Decode(r *http.Request) (Event, error) {
+	var payload NetlifyWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return Event{}, errors.Wrap(err, "decoding netlify payload")
+	}
+	return Event{
+		Kind:        "deploy",
+		Ref:         payload.Branch,
+		Status:      payload.State,
+		Environment: payload.Context,
+		Project:     payload.Name,
+	}, nil
+}
+
+// IsActionable checks the event against the configured filter, Netlify sends the same payload for every deploy state
+func (p *netlifyProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
+}
@@ -0,0 +1,106 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_netlifyProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		body string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      args
+		wantEvent Event
+		want      bool
+	}{
+		{
+			name:   "ready production deploy",
+			fields: fields{filter: "status=ready;environment=production"},
+			args: args{
+				body: `{"id":"1","site_id":"2","name":"annoying-technology","state":"ready","branch":"main","context":"production","url":"https://annoying.technology"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "main", Status: "ready", Environment: "production", Project: "annoying-technology"},
+			want:      true,
+		},
+		{
+			name:   "deploy still building",
+			fields: fields{filter: "status=ready;environment=production"},
+			args: args{
+				body: `{"name":"annoying-technology","state":"building","branch":"main","context":"production"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "main", Status: "building", Environment: "production", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "failed deploy",
+			fields: fields{filter: "status=ready;environment=production"},
+			args: args{
+				body: `{"name":"annoying-technology","state":"error","branch":"main","context":"production"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "main", Status: "error", Environment: "production", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "ready deploy preview",
+			fields: fields{filter: "status=ready;environment=production"},
+			args: args{
+				body: `{"name":"annoying-technology","state":"ready","branch":"feature","context":"deploy-preview"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "feature", Status: "ready", Environment: "deploy-preview", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "ready deploy on other branch",
+			fields: fields{filter: "status=ready;ref=main"},
+			args: args{
+				body: `{"name":"annoying-technology","state":"ready","branch":"release","context":"branch-deploy"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "release", Status: "ready", Environment: "branch-deploy", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "ready branch deploy",
+			fields: fields{filter: "status=ready;ref=main,release;environment=production,branch-deploy"},
+			args: args{
+				body: `{"name":"annoying-technology","state":"ready","branch":"release","context":"branch-deploy"}`,
+			},
+			wantEvent: Event{Kind: "deploy", Ref: "release", Status: "ready", Environment: "branch-deploy", Project: "annoying-technology"},
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewNetlifyProvider(filter)
+			r := httptest.NewRequest("POST", "/netlify/token", strings.NewReader(tt.args.body))
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if e != tt.wantEvent {
+				t.Errorf("Decode() = %+v, want %+v", e, tt.wantEvent)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_netlifyProvider_Decode_invalid(t *testing.T) {
+	p := NewNetlifyProvider(Filter{})
+	r := httptest.NewRequest("POST", "/netlify/token", strings.NewReader(`{"state":`))
+	if _, err := p.Decode(r); err == nil {
+		t.Error("Decode() error = nil, want error")
+	}
+}
@@ -0,0 +1,53 @@
+package hooklistener
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// Poll processes every feed right away and then every interval, plus a random delay of up to jitter so we don't hit
+// the feeds at the same time as everyone else. It's for hosts that can't send web hooks, or a safety net for missed
+// ones. The feeds are processed by the workers like they are for web hooks, a poll is skipped if there's already a job
+// waiting. Polling runs in the background until the context is cancelled.
+func (s *service) Poll(ctx context.Context, interval time.Duration, jitter time.Duration) {
+	for i := range s.feeds {
+		go s.poll(ctx, &s.feeds[i], interval, jitter)
+	}
+}
+
+func (s *service) poll(ctx context.Context, f *Feed, interval time.Duration, jitter time.Duration) {
+	level.Info(s.l).Log("msg", "polling feed", "feed", f.Name, "feed_url", f.URL, "interval", interval, "jitter", jitter)
+	for {
+		s.pollOnce(f)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(nextPoll(interval, jitter, rand.Int63n)):
+		}
+	}
+}
+
+// pollOnce queues a job to process the feed now, unless one is already waiting
+func (s *service) pollOnce(f *Feed) {
+	id, err := s.enqueue(f.Name, nil, time.Now())
+	if err != nil {
+		level.Error(s.l).Log("msg", "error enqueuing job", "feed", f.Name, "err", err)
+		return
+	}
+	if id == 0 {
+		level.Debug(s.l).Log("msg", "feed is already queued to be processed, skipping poll", "feed", f.Name)
+		return
+	}
+	level.Debug(s.l).Log("msg", "polled feed, job queued", "feed", f.Name, "job_id", id)
+}
+
+// nextPoll returns how long to wait until the next poll, random returns a number in [0, n)
+func nextPoll(interval time.Duration, jitter time.Duration, random func(n int64) int64) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	return interval + time.Duration(random(int64(jitter)))
+}
@@ -0,0 +1,96 @@
+package hooklistener
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dewey/webhook-receiver/queue"
+	"github.com/go-kit/log"
+)
+
+// enqueueRecorder records the payloads of queued jobs
+type enqueueRecorder struct {
+	queue.Repository
+	mu       sync.Mutex
+	payloads []string
+}
+
+func (q *enqueueRecorder) Scheduled(kind string, payload string, runAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (q *enqueueRecorder) Enqueue(kind string, payload string, runAt time.Time) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.payloads = append(q.payloads, payload)
+	return int64(len(q.payloads)), nil
+}
+
+func (q *enqueueRecorder) Payloads() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.payloads...)
+}
+
+func Test_service_Poll(t *testing.T) {
+	q := &enqueueRecorder{}
+	s := NewService(log.NewNopLogger(), nil, []Feed{{Name: "default"}, {Name: "podcast"}}, nil, q, nil, nil, PostingOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first poll doesn't wait for the interval
+	s.Poll(ctx, time.Hour, time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for len(q.Payloads()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := q.Payloads()
+	if len(got) != 2 {
+		t.Fatalf("Poll() queued %v, want a job for every feed", got)
+	}
+	for _, want := range []string{`{"feed":"default"}`, `{"feed":"podcast"}`} {
+		if got[0] != want && got[1] != want {
+			t.Errorf("Poll() queued %v, want %s", got, want)
+		}
+	}
+}
+
+func Test_nextPoll(t *testing.T) {
+	tests := []struct {
+		name   string
+		jitter time.Duration
+		random int64
+		want   time.Duration
+	}{
+		{
+			name: "without jitter",
+			want: 15 * time.Minute,
+		},
+		{
+			name:   "no random delay",
+			jitter: time.Minute,
+			want:   15 * time.Minute,
+		},
+		{
+			name:   "random delay",
+			jitter: time.Minute,
+			random: int64(30 * time.Second),
+			want:   15*time.Minute + 30*time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextPoll(15*time.Minute, tt.jitter, func(n int64) int64 {
+				if tt.random >= n {
+					t.Fatalf("random(%d) called with a too small n", n)
+				}
+				return tt.random
+			})
+			if got != tt.want {
+				t.Errorf("nextPoll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
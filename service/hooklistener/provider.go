@@ -0,0 +1,52 @@
+package hooklistener
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Event is the normalized subset of an incoming web hook that we need to decide if we should post something
+type Event struct {
+	// Kind is the type of event, e.g. "pipeline" for GitLab or "deploy" for Netlify
+	Kind string
+	// Ref is the branch the build or deploy was triggered for
+	Ref string
+	// Status is the provider specific state of the build or deploy, e.g. "success" or "ready"
+	Status string
+	// Environment is the deploy target, e.g. "production" or "preview"
+	Environment string
+	// Project is the name or path of the project or site the event belongs to
+	Project string
+	// Workflow is the name of the workflow or pipeline that sent the event, if the provider has more than one
+	Workflow string
+}
+
+// Provider is an interface for a web hook source like GitLab or Netlify
+type Provider interface {
+	// Decode parses the incoming request into an event
+	Decode(r *http.Request) (Event, error)
+	// IsActionable checks if the event should trigger a post, we filter out other hooks we receive and don't need
+	IsActionable(e Event) bool
+	String() string
+}
+
+// Providers is a list of enabled web hook providers
+type Providers []Provider
+
+// Get returns the provider with the given name, if it's enabled
+func (p Providers) Get(name string) (Provider, bool) {
+	for _, provider := range p {
+		if provider.String() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+func (p Providers) String() string {
+	var providers []string
+	for _, provider := range p {
+		providers = append(providers, provider.String())
+	}
+	return strings.Join(providers, ", ")
+}
@@ -1,60 +1,472 @@
 package hooklistener
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/dewey/webhook-receiver/cache"
 	"github.com/dewey/webhook-receiver/feed"
 	"github.com/dewey/webhook-receiver/notification"
+	"github.com/dewey/webhook-receiver/queue"
+	"github.com/dewey/webhook-receiver/schedule"
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/mmcdole/gofeed"
+	"github.com/pkg/errors"
 )
 
 // Service is an interface for a incoming hook listener service
 type Service interface {
-	ValidToken(uuid string) (bool, error)
+	FeedByToken(token string) (*Feed, bool)
+}
+
+// Feed is a feed we post from, every feed has its own hook token and notifiers
+type Feed struct {
+	// Name scopes the cache entries of the feed, it must not change once items were posted
+	Name      string
+	URL       string
+	HookToken string
+	Notifiers notification.Notifiers
+	// Cadences limit how often the notifiers of the feed post, keyed by notifier name. Notifiers without one use the
+	// default cadence of the service.
+	Cadences map[string]*schedule.Cadence
 }
 
 type service struct {
 	l         log.Logger
 	fr        feed.Repository
-	nr        []notification.Repository
+	feeds     []Feed
 	cr        cache.Repository
-	feedURL   string
-	hookToken string
+	qr        queue.Repository
+	providers Providers
+	verifiers map[string]Verifier
+	window    *schedule.Window
+	// defaultCadence is the cadence of notifiers without one of their own
+	defaultCadence *schedule.Cadence
+	// locks make sure a feed is only processed by one worker at a time, keyed by feed name
+	locks map[string]*sync.Mutex
 }
 
-// NewService initializes a new hook listener service
-func NewService(l log.Logger, fr feed.Repository, nr []notification.Repository, cr cache.Repository, feedURL string, hookToken string) *service {
+// PostingOptions control when the notifiers post
+type PostingOptions struct {
+	// Window is when posts are published, without one they are published right away
+	Window *schedule.Window
+	// DefaultCadence limits how often notifiers without a cadence of their own post, schedule.DefaultCadence in UTC if
+	// it's not set
+	DefaultCadence *schedule.Cadence
+}
+
+// NewService initializes a new hook listener service for the feeds. Verifiers are keyed by provider name, providers
+// without a verifier are authenticated with the hook token of a feed.
+func NewService(l log.Logger, fr feed.Repository, feeds []Feed, cr cache.Repository, qr queue.Repository, providers Providers, verifiers map[string]Verifier, posting PostingOptions) *service {
+	locks := make(map[string]*sync.Mutex, len(feeds))
+	for _, f := range feeds {
+		locks[f.Name] = &sync.Mutex{}
+	}
+	defaultCadence := posting.DefaultCadence
+	if defaultCadence == nil {
+		// The default cadence is a constant that always parses
+		defaultCadence, _ = schedule.ParseCadence(schedule.DefaultCadence, time.UTC)
+	}
 	return &service{
-		l:         l,
-		fr:        fr,
-		nr:        nr,
-		cr:        cr,
-		feedURL:   feedURL,
-		hookToken: hookToken,
+		l:              l,
+		fr:             fr,
+		feeds:          feeds,
+		cr:             cr,
+		qr:             qr,
+		providers:      providers,
+		verifiers:      verifiers,
+		window:         posting.Window,
+		defaultCadence: defaultCadence,
+		locks:          locks,
+	}
+}
+
+// FeedByToken returns the feed the hook token belongs to. Only we can trigger logic via the received webhook.
+func (s *service) FeedByToken(token string) (*Feed, bool) {
+	for i := range s.feeds {
+		if token != "" && token == s.feeds[i].HookToken {
+			return &s.feeds[i], true
+		}
+	}
+	return nil, false
+}
+
+// feedByName returns the feed with the name
+func (s *service) feedByName(name string) (*Feed, bool) {
+	for i := range s.feeds {
+		if s.feeds[i].Name == name {
+			return &s.feeds[i], true
+		}
+	}
+	return nil, false
+}
+
+// maxDeliveryAttempts is how often we try to post an item to a notification service before we skip it
+const maxDeliveryAttempts = 3
+
+// process fetches the feed and posts the uncached items to every notification service of the feed, as many as its
+// cadence allows. Items are claimed in the cache before posting and confirmed afterwards, if posting fails the item is
+// retried on the next run. Workers process a feed one after another, otherwise they could both count the posts before
+// either of them posted and go over the cadence.
+func (s *service) process(ctx context.Context, f *Feed) error {
+	mu := s.locks[f.Name]
+	mu.Lock()
+	defer mu.Unlock()
+
+	l := log.With(s.l, "feed", f.Name)
+	feed, err := s.fr.Feed(ctx, f.URL)
+	if err != nil {
+		return errors.Wrap(err, "parsing feed")
+	}
+
+	t := time.Now()
+	// Outside of the posting window, posts are published when it opens next
+	postAt := t
+	if s.window != nil {
+		postAt = s.window.Next(t)
+	}
+	var (
+		failed int
+		// runAgain is when we have to come back for notifiers that had to wait
+		runAgain time.Time
+	)
+	later := func(at time.Time) {
+		if runAgain.IsZero() || at.Before(runAgain) {
+			runAgain = at
+		}
 	}
+	// Usually every notifier posts the same item, so we only look up its image once
+	notificationItems := make(map[string]notification.Item)
+	for _, notificationService := range f.Notifiers {
+		name := notificationService.String()
+		cadence := s.cadence(f, name)
+		// Without a limit a new notifier would post the whole feed at once, what's in it already counts as handled
+		if cadence.Immediate {
+			skipped, err := s.skipExisting(f.Name, feed.Items, name, t)
+			if err != nil {
+				level.Error(l).Log("err", err)
+				continue
+			}
+			if skipped > 0 {
+				level.Info(l).Log("msg", "new notification service, skipped the items already in the feed", "notification_service", name, "count", skipped)
+				continue
+			}
+		}
+		// Notifiers that can't schedule posts themselves have to wait for the window
+		scheduler, canSchedule := notificationService.(notification.Scheduler)
+		if postAt.After(t) && !canSchedule {
+			level.Debug(l).Log("msg", "outside of posting window, deferring", "notification_service", name, "post_at", postAt)
+			later(postAt)
+			continue
+		}
+
+		allowed, next, err := s.allowedPosts(f.Name, name, cadence, postAt)
+		if err != nil {
+			level.Error(l).Log("err", err)
+			continue
+		}
+		// We look for one more item than we can post, to know if there's a backlog left
+		limit := allowed
+		if limit >= 0 {
+			limit++
+		}
+		items, err := s.getUncachedFeedItems(f.Name, feed.Items, name, limit)
+		if err != nil {
+			level.Error(l).Log("err", err)
+			continue
+		}
+		backlog := allowed >= 0 && len(items) > allowed
+		if backlog {
+			items = items[:allowed]
+		}
+		if allowed == 0 {
+			level.Debug(l).Log("msg", "cadence doesn't allow another post yet, skipping", "notification_service", name, "next", next)
+			if cadence.Drip && backlog {
+				later(next)
+			}
+			continue
+		}
+
+		for _, item := range items {
+			claimed, err := s.cr.Claim(f.Name, item.GUID, name, postAt, time.Now().Add(-claimLease))
+			if err != nil {
+				level.Error(l).Log("err", err)
+				continue
+			}
+			// Another worker was faster, it's already being posted
+			if !claimed {
+				level.Debug(l).Log("msg", "item already claimed, skipping", "guid", item.GUID, "notification_service", name)
+				continue
+			}
+
+			level.Info(l).Log("msg", "cache miss, send notification", "guid", item.GUID, "notification_service", name)
+			notificationItem, ok := notificationItems[item.GUID]
+			if !ok {
+				notificationItem = s.newNotificationItemWithImage(ctx, feed, item)
+				notificationItem.Feed = f.Name
+				notificationItems[item.GUID] = notificationItem
+			}
+			var result *notification.Result
+			if postAt.After(t) {
+				level.Info(l).Log("msg", "outside of posting window, scheduling", "guid", item.GUID, "notification_service", name, "post_at", postAt)
+				result, err = scheduler.Schedule(ctx, notificationItem, postAt)
+			} else {
+				result, err = notificationService.Post(ctx, notificationItem)
+			}
+			if err != nil {
+				failed++
+				level.Error(l).Log("msg", "error posting, marking item as failed", "guid", item.GUID, "notification_service", name, "err", err)
+				if err := s.cr.MarkFailed(f.Name, item.GUID, name, err); err != nil {
+					level.Error(l).Log("err", err)
+				}
+				continue
+			}
+			if err := s.cr.MarkSent(f.Name, item.GUID, name, result.ID, result.URL, result.PublishedAt); err != nil {
+				level.Error(l).Log("err", err)
+				continue
+			}
+		}
+
+		// The posts used up the limits, the rest of the backlog follows once the next post is allowed
+		if cadence.Drip && backlog {
+			if _, next, err := s.allowedPosts(f.Name, name, cadence, postAt); err != nil {
+				level.Error(l).Log("err", err)
+			} else if !next.IsZero() {
+				later(next)
+			}
+		}
+	}
+	if !runAgain.IsZero() {
+		if err := s.runAt(f.Name, runAgain); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("posting to %d notification services failed", failed)
+	}
+	return nil
 }
 
-// ValidToken checks if the given token is a valid token. Only we can trigger logic via the received webhook.
-func (s *service) ValidToken(uuid string) (bool, error) {
-	if uuid != "" && uuid == s.hookToken {
-		return true, nil
+// cadence returns the cadence of a notification service of the feed
+func (s *service) cadence(f *Feed, notificationService string) *schedule.Cadence {
+	if c, ok := f.Cadences[notificationService]; ok {
+		return c
 	}
-	return false, nil
+	return s.defaultCadence
 }
 
-// getUncachedFeedItem returns a feed item if there's something new and uncached
-func (s *service) getNextUncachedFeedItem(items []*gofeed.Item, notificationService string) (*gofeed.Item, bool, error) {
-	// For each iteration we only send one notification even if there are more cache misses (aka. unsent tweets). This acts
-	// as a natural rate limit and jittering, and they are more spread out.
+// allowedPosts returns how many posts of the feed the cadence of the notification service allows at t and when to
+// check again if the answer is none. A negative number means there is no limit.
+func (s *service) allowedPosts(feed string, notificationService string, cadence *schedule.Cadence, t time.Time) (int, time.Time, error) {
+	if cadence.Immediate {
+		return -1, time.Time{}, nil
+	}
+	var (
+		recent int
+		oldest time.Time
+	)
+	if cadence.Per > 0 {
+		var err error
+		if recent, oldest, err = s.cr.CountSince(feed, notificationService, cadence.Since(t)); err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+	last, _, err := s.cr.LastPublished(feed, notificationService)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	allowed, next := cadence.Allowed(t, recent, oldest, last)
+	return allowed, next, nil
+}
+
+// runAt queues a job to process the feed again at the given time, unless one is already queued to run before that
+func (s *service) runAt(feed string, at time.Time) error {
+	id, err := s.enqueue(feed, nil, at)
+	if err != nil {
+		return errors.Wrap(err, "scheduling job")
+	}
+	if id != 0 {
+		level.Info(s.l).Log("msg", "scheduled job to process the feed again", "feed", feed, "run_at", at)
+	}
+	return nil
+}
+
+// jobPayload is the payload of feed processing jobs. Jobs from before we supported multiple feeds only have the
+// event, they process every feed.
+type jobPayload struct {
+	Feed  string `json:"feed,omitempty"`
+	Event *Event `json:"event,omitempty"`
+}
+
+// enqueue queues a job to process the feed at the given time, an empty feed processes all of them. Jobs without an
+// event are only queued if there's no such job queued to run before that already, the id is 0 if that's the case.
+func (s *service) enqueue(feed string, event *Event, at time.Time) (int64, error) {
+	b, err := json.Marshal(jobPayload{Feed: feed, Event: event})
+	if err != nil {
+		return 0, errors.Wrap(err, "encoding job payload")
+	}
+	payload := string(b)
+	if event == nil {
+		scheduled, err := s.qr.Scheduled(queue.KindProcessFeed, payload, at)
+		if err != nil {
+			return 0, errors.Wrap(err, "checking for scheduled jobs")
+		}
+		if scheduled {
+			return 0, nil
+		}
+	}
+	return s.qr.Enqueue(queue.KindProcessFeed, payload, at)
+}
+
+// skipExisting marks all items of the feed as skipped if the notification service has never seen the feed before, and
+// returns how many there were
+func (s *service) skipExisting(feed string, items []*gofeed.Item, notificationService string, t time.Time) (int, error) {
+	seen, err := s.cr.Seen(feed, notificationService)
+	if err != nil || seen {
+		return 0, err
+	}
+	for _, item := range items {
+		if err := s.cr.Skip(feed, item.GUID, notificationService, t); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// getUncachedFeedItems returns up to limit items of the feed that are new and uncached, or all of them if the limit is
+// negative. Items that failed to post before, or whose post was never confirmed, are returned again until they run out
+// of attempts.
+func (s *service) getUncachedFeedItems(feed string, items []*gofeed.Item, notificationService string, limit int) ([]*gofeed.Item, error) {
+	var uncached []*gofeed.Item
 	for _, item := range items {
-		_, exists, err := s.cr.Get(item.GUID, notificationService)
+		if limit >= 0 && len(uncached) >= limit {
+			break
+		}
+		entry, exists, err := s.cr.Get(feed, item.GUID, notificationService)
 		if err != nil {
-			return nil, false, err
+			return nil, err
 		}
 		// Item doesn't exist in cache yet, it still needs to be posted
 		if !exists {
-			return item, false, nil
+			uncached = append(uncached, item)
+			continue
+		}
+		stale := entry.State == cache.StatePending && entry.UpdatedAt.Before(time.Now().Add(-claimLease))
+		if entry.State == cache.StateFailed || stale {
+			if entry.Attempts < maxDeliveryAttempts {
+				uncached = append(uncached, item)
+				continue
+			}
+			level.Debug(s.l).Log("msg", "item ran out of delivery attempts, skipping", "feed", feed, "guid", item.GUID, "notification_service", notificationService, "last_error", entry.LastError)
+		}
+	}
+	return uncached, nil
+}
+
+// newNotificationItem converts a feed item into what the notifiers and their templates work with
+func newNotificationItem(feed *gofeed.Feed, item *gofeed.Item) notification.Item {
+	i := notification.Item{
+		GUID:       item.GUID,
+		Title:      item.Title,
+		Summary:    item.Description,
+		Content:    item.Content,
+		Categories: item.Categories,
+		Link:       item.Link,
+	}
+	if item.Author != nil {
+		i.Author = item.Author.Name
+	}
+	i.Image, i.ImageAlt = itemImage(item)
+	// The language of an item is rarely set, usually it's the language of the feed
+	i.Language = feed.Language
+	if item.DublinCoreExt != nil && len(item.DublinCoreExt.Language) > 0 {
+		i.Language = item.DublinCoreExt.Language[0]
+	}
+	if item.PublishedParsed != nil {
+		i.Published = *item.PublishedParsed
+	} else if item.UpdatedParsed != nil {
+		i.Published = *item.UpdatedParsed
+	}
+	return i
+}
+
+// newNotificationItemWithImage converts the feed item and falls back to the og:image of the linked page if the item
+// doesn't have an image
+func (s *service) newNotificationItemWithImage(ctx context.Context, feed *gofeed.Feed, item *gofeed.Item) notification.Item {
+	i := newNotificationItem(feed, item)
+	if i.Image != "" || i.Link == "" {
+		return i
+	}
+	image, err := s.fr.PageImage(ctx, i.Link)
+	if err != nil {
+		level.Warn(s.l).Log("msg", "error looking up og:image, posting without image", "link", i.Link, "err", err)
+		return i
+	}
+	if image != nil {
+		i.Image = image.URL
+		i.ImageAlt = image.Alt
+		if i.ImageAlt == "" {
+			i.ImageAlt = i.Title
+		}
+	}
+	return i
+}
+
+// itemImage returns the image of a feed item and its alt text. The image is taken from the item itself, an image
+// enclosure or Media RSS. Images without a title of their own are described by the title of the item.
+func itemImage(item *gofeed.Item) (string, string) {
+	var imageURL, alt string
+	switch {
+	case item.Image != nil && item.Image.URL != "":
+		imageURL, alt = item.Image.URL, item.Image.Title
+	case imageEnclosure(item) != "":
+		imageURL = imageEnclosure(item)
+	default:
+		imageURL, alt = mediaImage(item)
+	}
+	if imageURL != "" && alt == "" {
+		alt = item.Title
+	}
+	return imageURL, alt
+}
+
+// imageEnclosure returns the first enclosure that is an image
+func imageEnclosure(item *gofeed.Item) string {
+	for _, enclosure := range item.Enclosures {
+		if strings.HasPrefix(enclosure.Type, "image/") {
+			return enclosure.URL
+		}
+	}
+	return ""
+}
+
+// mediaImage returns the first image in the Media RSS extension (media:content or media:thumbnail) and its description
+func mediaImage(item *gofeed.Item) (string, string) {
+	media, ok := item.Extensions["media"]
+	if !ok {
+		return "", ""
+	}
+	for _, name := range []string{"content", "thumbnail"} {
+		for _, e := range media[name] {
+			if e.Attrs["url"] == "" {
+				continue
+			}
+			if name == "content" && e.Attrs["medium"] != "image" && !strings.HasPrefix(e.Attrs["type"], "image/") {
+				continue
+			}
+			var alt string
+			for _, child := range []string{"description", "title"} {
+				if c, ok := e.Children[child]; ok && len(c) > 0 && c[0].Value != "" {
+					alt = c[0].Value
+					break
+				}
+			}
+			return e.Attrs["url"], alt
 		}
 	}
-	return nil, true, nil
+	return "", ""
 }
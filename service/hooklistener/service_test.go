@@ -0,0 +1,278 @@
+package hooklistener
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dewey/webhook-receiver/cache"
+	"github.com/dewey/webhook-receiver/database/databasetest"
+	"github.com/dewey/webhook-receiver/feed"
+	"github.com/dewey/webhook-receiver/notification"
+	"github.com/dewey/webhook-receiver/schedule"
+	"github.com/go-kit/log"
+	"github.com/mmcdole/gofeed"
+)
+
+// fakeFeeds serves feeds with the given items, keyed by url
+type fakeFeeds struct {
+	mu    sync.Mutex
+	items map[string][]string
+}
+
+func (f *fakeFeeds) set(url string, guids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[url] = guids
+}
+
+func (f *fakeFeeds) Feed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var items []*gofeed.Item
+	for _, guid := range f.items[feedURL] {
+		items = append(items, &gofeed.Item{GUID: guid, Title: guid, Link: "https://example.com/" + guid})
+	}
+	return &gofeed.Feed{Items: items}, nil
+}
+
+func (f *fakeFeeds) PageImage(ctx context.Context, pageURL string) (*feed.Image, error) {
+	return nil, nil
+}
+
+// recordingNotifier remembers the GUIDs of the items it posted
+type recordingNotifier struct {
+	mu     sync.Mutex
+	name   string
+	posted []string
+}
+
+func (n *recordingNotifier) String() string {
+	return n.name
+}
+
+func (n *recordingNotifier) Post(ctx context.Context, item notification.Item) (*notification.Result, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.posted = append(n.posted, item.GUID)
+	return &notification.Result{PublishedAt: time.Now()}, nil
+}
+
+func (n *recordingNotifier) Posted() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.posted...)
+}
+
+// newTestCache returns a cache repository on a new database, migrated with the migrations of the api
+func newTestCache(t *testing.T) cache.Repository {
+	t.Helper()
+	cr, err := cache.NewRepository(log.NewNopLogger(), databasetest.NewDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cr
+}
+
+// slowCache counts posts right away, but returns the counts one after another with a delay in between. Workers that
+// counted at the same time continue at different times.
+type slowCache struct {
+	cache.Repository
+	delay time.Duration
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *slowCache) CountSince(feed string, notificationService string, since time.Time) (int, time.Time, error) {
+	count, oldest, err := c.Repository.CountSince(feed, notificationService, since)
+	c.mu.Lock()
+	c.calls++
+	wait := time.Duration(c.calls) * c.delay
+	c.mu.Unlock()
+	time.Sleep(wait)
+	return count, oldest, err
+}
+
+func mustCadence(t *testing.T, expr string) *schedule.Cadence {
+	t.Helper()
+	c, err := schedule.ParseCadence(expr, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func Test_itemImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    string
+		wantURL string
+		wantAlt string
+	}{
+		{
+			name:    "enclosure",
+			item:    `<item><title>Post</title><enclosure url="https://example.com/a.mp3" type="audio/mpeg" length="1"/><enclosure url="https://example.com/a.png" type="image/png" length="1"/></item>`,
+			wantURL: "https://example.com/a.png",
+			wantAlt: "Post",
+		},
+		{
+			name:    "media content with description",
+			item:    `<item><title>Post</title><media:content url="https://example.com/a.jpg" medium="image"><media:description>A screenshot</media:description></media:content></item>`,
+			wantURL: "https://example.com/a.jpg",
+			wantAlt: "A screenshot",
+		},
+		{
+			name:    "media thumbnail",
+			item:    `<item><title>Post</title><media:content url="https://example.com/a.mp4" medium="video"/><media:thumbnail url="https://example.com/a.jpg"/></item>`,
+			wantURL: "https://example.com/a.jpg",
+			wantAlt: "Post",
+		},
+		{
+			name: "no image",
+			item: `<item><title>Post</title><enclosure url="https://example.com/a.mp3" type="audio/mpeg" length="1"/></item>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := gofeed.NewParser().ParseString(`<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/"><channel>` + tt.item + `</channel></rss>`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotURL, gotAlt := itemImage(feed.Items[0])
+			if gotURL != tt.wantURL || gotAlt != tt.wantAlt {
+				t.Errorf("itemImage() = %q, %q, want %q, %q", gotURL, gotAlt, tt.wantURL, tt.wantAlt)
+			}
+		})
+	}
+}
+
+func Test_service_FeedByToken(t *testing.T) {
+	s := NewService(nil, nil, []Feed{
+		{Name: "default", HookToken: "changeme"},
+		{Name: "podcast", HookToken: "secret"},
+	}, nil, nil, nil, nil, PostingOptions{})
+	tests := []struct {
+		token    string
+		wantFeed string
+		wantOK   bool
+	}{
+		{token: "changeme", wantFeed: "default", wantOK: true},
+		{token: "secret", wantFeed: "podcast", wantOK: true},
+		{token: "wrong"},
+		{token: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			f, ok := s.FeedByToken(tt.token)
+			if ok != tt.wantOK {
+				t.Fatalf("FeedByToken() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && f.Name != tt.wantFeed {
+				t.Errorf("FeedByToken() = %s, want %s", f.Name, tt.wantFeed)
+			}
+		})
+	}
+}
+
+func Test_service_process_newNotifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		cadence    string
+		wantFirst  []string
+		wantSecond []string
+	}{
+		// The items in the feed when the notifier is first seen aren't posted, only the ones after that
+		{name: "immediate", cadence: "immediate", wantSecond: []string{"guid-3"}},
+		// Cadences with a limit work through the backlog as they allow
+		{name: "limited", cadence: "posts=5;per=day", wantFirst: []string{"guid-1", "guid-2"}, wantSecond: []string{"guid-1", "guid-2", "guid-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeds := &fakeFeeds{items: map[string][]string{}}
+			feeds.set("https://example.com/index.xml", "guid-1", "guid-2")
+			n := &recordingNotifier{name: "mastodon"}
+			f := Feed{Name: "default", URL: "https://example.com/index.xml", Notifiers: notification.Notifiers{n}}
+			s := NewService(log.NewNopLogger(), feeds, []Feed{f}, newTestCache(t), nil, nil, nil, PostingOptions{DefaultCadence: mustCadence(t, tt.cadence)})
+
+			if err := s.process(context.Background(), &s.feeds[0]); err != nil {
+				t.Fatal(err)
+			}
+			if got := n.Posted(); !equalStrings(got, tt.wantFirst) {
+				t.Errorf("first run posted %v, want %v", got, tt.wantFirst)
+			}
+			feeds.set("https://example.com/index.xml", "guid-3", "guid-1", "guid-2")
+			if err := s.process(context.Background(), &s.feeds[0]); err != nil {
+				t.Fatal(err)
+			}
+			if got := n.Posted(); !equalStrings(got, tt.wantSecond) {
+				t.Errorf("second run posted %v, want %v", got, tt.wantSecond)
+			}
+		})
+	}
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_service_process_feeds(t *testing.T) {
+	feeds := &fakeFeeds{items: map[string][]string{}}
+	feeds.set("https://example.com/index.xml", "guid-1", "guid-2")
+	feeds.set("https://example.com/podcast.xml", "guid-1", "guid-3")
+	blog := &recordingNotifier{name: "mastodon"}
+	podcast := &recordingNotifier{name: "mastodon"}
+	s := NewService(log.NewNopLogger(), feeds, []Feed{
+		{Name: "default", URL: "https://example.com/index.xml", Notifiers: notification.Notifiers{blog}},
+		{Name: "podcast", URL: "https://example.com/podcast.xml", Notifiers: notification.Notifiers{podcast}, Cadences: map[string]*schedule.Cadence{
+			"mastodon": mustCadence(t, "posts=1;per=day"),
+		}},
+	}, newTestCache(t), nil, nil, nil, PostingOptions{DefaultCadence: mustCadence(t, "posts=5;per=day")})
+
+	// The feeds share the cache but not their items or cadences, the same GUID is posted for both of them
+	for i := range s.feeds {
+		if err := s.process(context.Background(), &s.feeds[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := blog.Posted(), []string{"guid-1", "guid-2"}; !equalStrings(got, want) {
+		t.Errorf("default feed posted %v, want %v", got, want)
+	}
+	if got, want := podcast.Posted(), []string{"guid-1"}; !equalStrings(got, want) {
+		t.Errorf("podcast feed posted %v, want %v", got, want)
+	}
+}
+
+func Test_service_process_concurrent(t *testing.T) {
+	feeds := &fakeFeeds{items: map[string][]string{}}
+	feeds.set("https://example.com/index.xml", "guid-1", "guid-2", "guid-3")
+	n := &recordingNotifier{name: "mastodon"}
+	f := Feed{Name: "default", URL: "https://example.com/index.xml", Notifiers: notification.Notifiers{n}}
+	cr := &slowCache{Repository: newTestCache(t), delay: 50 * time.Millisecond}
+	// Without a cadence the default of one post per day applies
+	s := NewService(log.NewNopLogger(), feeds, []Feed{f}, cr, nil, nil, nil, PostingOptions{})
+
+	// Jobs of the same feed run by different workers must not both see the cadence allowing a post
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.process(context.Background(), &s.feeds[0]); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := n.Posted(); len(got) != 1 {
+		t.Errorf("posted %v, want a single post", got)
+	}
+}
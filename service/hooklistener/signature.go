@@ -0,0 +1,154 @@
+package hooklistener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSignature is returned if the signature of a request is missing or doesn't match the body
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Verifier is an interface for authenticating an incoming web hook request. All verifiers work on the raw body, before
+// it's decoded.
+type Verifier interface {
+	Verify(h http.Header, body []byte) error
+}
+
+type githubVerifier struct {
+	secret []byte
+}
+
+// NewGithubVerifier initializes a new verifier for the "X-Hub-Signature-256" header GitHub sends if a secret is set
+func NewGithubVerifier(secret string) *githubVerifier {
+	return &githubVerifier{
+		secret: []byte(secret),
+	}
+}
+
+func (v *githubVerifier) Verify(h http.Header, body []byte) error {
+	signature, ok := strings.CutPrefix(h.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return verifyHexHMAC(v.secret, body, signature)
+}
+
+type gitlabVerifier struct {
+	token []byte
+}
+
+// NewGitlabVerifier initializes a new verifier for the "X-Gitlab-Token" header. GitLab doesn't sign the body, it sends
+// the configured secret token as is.
+func NewGitlabVerifier(token string) *gitlabVerifier {
+	return &gitlabVerifier{
+		token: []byte(token),
+	}
+}
+
+func (v *gitlabVerifier) Verify(h http.Header, body []byte) error {
+	if subtle.ConstantTimeCompare([]byte(h.Get("X-Gitlab-Token")), v.token) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type netlifyVerifier struct {
+	secret []byte
+}
+
+// NewNetlifyVerifier initializes a new verifier for the JSON Web Signature Netlify sends in the "X-Webhook-Signature"
+// header if a JWS secret is set on the notification.
+func NewNetlifyVerifier(secret string) *netlifyVerifier {
+	return &netlifyVerifier{
+		secret: []byte(secret),
+	}
+}
+
+func (v *netlifyVerifier) Verify(h http.Header, body []byte) error {
+	parts := strings.Split(h.Get("X-Webhook-Signature"), ".")
+	if len(parts) != 3 {
+		return ErrInvalidSignature
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWSPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return ErrInvalidSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+
+	// The signed claims contain a hash of the body, otherwise the token could be replayed with a different body
+	var claims struct {
+		Issuer string `json:"iss"`
+		SHA256 string `json:"sha256"`
+	}
+	if err := decodeJWSPart(parts[1], &claims); err != nil || claims.Issuer != "netlify" {
+		return ErrInvalidSignature
+	}
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(claims.SHA256), []byte(hex.EncodeToString(sum[:]))) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type hmacVerifier struct {
+	secret []byte
+	header string
+}
+
+// NewHMACVerifier initializes a new verifier for a hex encoded HMAC-SHA256 of the body in the given header. An optional
+// "sha256=" prefix is ignored.
+func NewHMACVerifier(secret string, header string) *hmacVerifier {
+	return &hmacVerifier{
+		secret: []byte(secret),
+		header: header,
+	}
+}
+
+func (v *hmacVerifier) Verify(h http.Header, body []byte) error {
+	signature := strings.TrimPrefix(h.Get(v.header), "sha256=")
+	if signature == "" {
+		return ErrInvalidSignature
+	}
+	return verifyHexHMAC(v.secret, body, signature)
+}
+
+// verifyHexHMAC compares the hex encoded signature with the HMAC-SHA256 of the body in constant time
+func verifyHexHMAC(secret []byte, body []byte, signature string) error {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(expected, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func decodeJWSPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,108 @@
+package hooklistener
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func sign(secret string, data string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func netlifySignature(secret string, body string) string {
+	sum := sha256.Sum256([]byte(body))
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"netlify","sha256":"` + hex.EncodeToString(sum[:]) + `"}`))
+	return header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(sign(secret, header+"."+claims))
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	body := `{"state":"ready"}`
+	tests := []struct {
+		name     string
+		verifier Verifier
+		header   http.Header
+		body     string
+		wantErr  bool
+	}{
+		{
+			name:     "github valid signature",
+			verifier: NewGithubVerifier("secret"),
+			header:   http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(sign("secret", body))}},
+			body:     body,
+		},
+		{
+			name:     "github signature with wrong secret",
+			verifier: NewGithubVerifier("secret"),
+			header:   http.Header{"X-Hub-Signature-256": {"sha256=" + hex.EncodeToString(sign("other", body))}},
+			body:     body,
+			wantErr:  true,
+		},
+		{
+			name:     "github missing signature",
+			verifier: NewGithubVerifier("secret"),
+			header:   http.Header{},
+			body:     body,
+			wantErr:  true,
+		},
+		{
+			name:     "gitlab valid token",
+			verifier: NewGitlabVerifier("secret"),
+			header:   http.Header{"X-Gitlab-Token": {"secret"}},
+			body:     body,
+		},
+		{
+			name:     "gitlab invalid token",
+			verifier: NewGitlabVerifier("secret"),
+			header:   http.Header{"X-Gitlab-Token": {"secre"}},
+			body:     body,
+			wantErr:  true,
+		},
+		{
+			name:     "netlify valid signature",
+			verifier: NewNetlifyVerifier("secret"),
+			header:   http.Header{"X-Webhook-Signature": {netlifySignature("secret", body)}},
+			body:     body,
+		},
+		{
+			name:     "netlify signature for different body",
+			verifier: NewNetlifyVerifier("secret"),
+			header:   http.Header{"X-Webhook-Signature": {netlifySignature("secret", `{"state":"error"}`)}},
+			body:     body,
+			wantErr:  true,
+		},
+		{
+			name:     "netlify signature with wrong secret",
+			verifier: NewNetlifyVerifier("secret"),
+			header:   http.Header{"X-Webhook-Signature": {netlifySignature("other", body)}},
+			body:     body,
+			wantErr:  true,
+		},
+		{
+			name:     "generic valid signature",
+			verifier: NewHMACVerifier("secret", "X-Signature-256"),
+			header:   http.Header{"X-Signature-256": {hex.EncodeToString(sign("secret", body))}},
+			body:     body,
+		},
+		{
+			name:     "generic tampered body",
+			verifier: NewHMACVerifier("secret", "X-Signature-256"),
+			header:   http.Header{"X-Signature-256": {hex.EncodeToString(sign("secret", body))}},
+			body:     body + " ",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.verifier.Verify(tt.header, []byte(tt.body)); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
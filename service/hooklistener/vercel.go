@@ -0,0 +1,68 @@
+package hooklistener
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// VercelWebhookPayload is part of the payload Vercel sends us for deployment events
+type VercelWebhookPayload struct {
+	Type    string `json:"type"`
+	Payload struct {
+		Name   string `json:"name"`
+		Target string `json:"target"`
+		// Deployment.Meta contains the git information, depending on the connected git provider
+		Deployment struct {
+			Meta struct {
+				GithubCommitRef    string `json:"githubCommitRef"`
+				GitlabCommitRef    string `json:"gitlabCommitRef"`
+				BitbucketCommitRef string `json:"bitbucketCommitRef"`
+			} `json:"meta"`
+		} `json:"deployment"`
+	} `json:"payload"`
+}
+
+type vercelProvider struct {
+	filter Filter
+}
+
+// NewVercelProvider initializes a new provider for Vercel deployment webhooks. The deployment target is available as
+// the environment of the event.
+func NewVercelProvider(filter Filter) *vercelProvider {
+	return &vercelProvider{
+		filter: filter,
+	}
+}
+
+func (p *vercelProvider) String() string {
+	return "vercel"
+}
+
+func (p *vercelProvider) Decode(r *http.Request) (Event, error) {
+	var payload VercelWebhookPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		return Event{}, errors.Wrap(err, "decoding vercel payload")
+	}
+	ref := payload.Payload.Deployment.Meta.GithubCommitRef
+	if ref == "" {
+		ref = payload.Payload.Deployment.Meta.GitlabCommitRef
+	}
+	if ref == "" {
+		ref = payload.Payload.Deployment.Meta.BitbucketCommitRef
+	}
+	return Event{
+		Kind:        payload.Type,
+		Ref:         ref,
+		Status:      payload.Type,
+		Environment: payload.Payload.Target,
+		Project:     payload.Payload.Name,
+	}, nil
+}
+
+// IsActionable checks the event against the configured filter. Successful deployments are "deployment.succeeded", or
+// "deployment-ready" for older web hooks.
+func (p *vercelProvider) IsActionable(e Event) bool {
+	return p.filter.Match(e)
+}
@@ -0,0 +1,88 @@
+package hooklistener
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_vercelProvider_IsActionable(t *testing.T) {
+	type fields struct {
+		filter string
+	}
+	type args struct {
+		body string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      args
+		wantEvent Event
+		want      bool
+	}{
+		{
+			name:   "succeeded production deployment",
+			fields: fields{filter: "kind=deployment.succeeded,deployment-ready;environment=production"},
+			args: args{
+				body: `{"type":"deployment.succeeded","payload":{"name":"annoying-technology","target":"production","deployment":{"meta":{"githubCommitRef":"main"}}}}`,
+			},
+			wantEvent: Event{Kind: "deployment.succeeded", Ref: "main", Status: "deployment.succeeded", Environment: "production", Project: "annoying-technology"},
+			want:      true,
+		},
+		{
+			name:   "ready production deployment of older web hooks",
+			fields: fields{filter: "kind=deployment.succeeded,deployment-ready;environment=production"},
+			args: args{
+				body: `{"type":"deployment-ready","payload":{"name":"annoying-technology","target":"production","deployment":{"meta":{"gitlabCommitRef":"main"}}}}`,
+			},
+			wantEvent: Event{Kind: "deployment-ready", Ref: "main", Status: "deployment-ready", Environment: "production", Project: "annoying-technology"},
+			want:      true,
+		},
+		{
+			name:   "created production deployment",
+			fields: fields{filter: "kind=deployment.succeeded,deployment-ready;environment=production"},
+			args: args{
+				body: `{"type":"deployment.created","payload":{"name":"annoying-technology","target":"production","deployment":{"meta":{"githubCommitRef":"main"}}}}`,
+			},
+			wantEvent: Event{Kind: "deployment.created", Ref: "main", Status: "deployment.created", Environment: "production", Project: "annoying-technology"},
+			want:      false,
+		},
+		{
+			name:   "succeeded preview deployment",
+			fields: fields{filter: "kind=deployment.succeeded,deployment-ready;environment=production"},
+			args: args{
+				body: `{"type":"deployment.succeeded","payload":{"name":"annoying-technology","target":null,"deployment":{"meta":{"bitbucketCommitRef":"feature"}}}}`,
+			},
+			wantEvent: Event{Kind: "deployment.succeeded", Ref: "feature", Status: "deployment.succeeded", Project: "annoying-technology"},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.fields.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			p := NewVercelProvider(filter)
+			r := httptest.NewRequest("POST", "/vercel/token", strings.NewReader(tt.args.body))
+			e, err := p.Decode(r)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if e != tt.wantEvent {
+				t.Errorf("Decode() = %+v, want %+v", e, tt.wantEvent)
+			}
+			if got := p.IsActionable(e); got != tt.want {
+				t.Errorf("IsActionable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vercelProvider_Decode_invalid(t *testing.T) {
+	p := NewVercelProvider(Filter{})
+	r := httptest.NewRequest("POST", "/vercel/token", strings.NewReader(`{"kind":`))
+	if _, err := p.Decode(r); err == nil {
+		t.Error("Decode() error = nil, want error")
+	}
+}
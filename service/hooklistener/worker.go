@@ -0,0 +1,164 @@
+package hooklistener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dewey/webhook-receiver/queue"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// pollInterval is how long an idle worker waits before checking the queue for new jobs again
+	pollInterval = 2 * time.Second
+	// jobTimeout is the maximum time a single job is allowed to take
+	jobTimeout = 5 * time.Minute
+	// claimLease is how long a claimed cache entry is left alone, after the job timed out it can't be posting anymore
+	claimLease = jobTimeout
+	// maxJobAttempts is how often a job is tried before it's marked as failed
+	maxJobAttempts = 5
+	// retryBackoff is the delay before the first retry of a failed job, it doubles with every attempt
+	retryBackoff = 30 * time.Second
+	// jobRetention is how long done and failed jobs are kept around for debugging before they are pruned
+	jobRetention = 7 * 24 * time.Hour
+	// pruneInterval is how often old jobs are pruned
+	pruneInterval = time.Hour
+)
+
+// Start recovers jobs and posts that were interrupted by a restart and starts the given number of workers processing
+// the job queue, and the pruning of old jobs. The workers stop once the context is cancelled.
+func (s *service) Start(ctx context.Context, workers int) error {
+	recovered, err := s.qr.Recover()
+	if err != nil {
+		return err
+	}
+	if recovered > 0 {
+		level.Info(s.l).Log("msg", "requeued interrupted jobs", "count", recovered)
+	}
+	interrupted, err := s.cr.Recover(time.Now().Add(-claimLease))
+	if err != nil {
+		return err
+	}
+	if interrupted > 0 {
+		level.Info(s.l).Log("msg", "marked interrupted posts as failed, they are retried", "count", interrupted)
+	}
+	for i := 0; i < workers; i++ {
+		go s.work(ctx, i)
+	}
+	go s.prune(ctx)
+	return nil
+}
+
+// prune deletes done and failed jobs once they are older than the retention, so the jobs table doesn't grow forever
+func (s *service) prune(ctx context.Context) {
+	for {
+		pruned, err := s.qr.Prune(time.Now().Add(-jobRetention))
+		if err != nil {
+			level.Error(s.l).Log("msg", "error pruning jobs", "err", err)
+		} else if pruned > 0 {
+			level.Debug(s.l).Log("msg", "pruned old jobs", "count", pruned)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pruneInterval):
+		}
+	}
+}
+
+func (s *service) work(ctx context.Context, worker int) {
+	for {
+		job, ok, err := s.qr.Claim(time.Now())
+		if err != nil {
+			level.Error(s.l).Log("msg", "error claiming job", "worker", worker, "err", err)
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		s.runJob(job, worker)
+	}
+}
+
+// processJob processes the feed of a job, or every feed for jobs from before we supported multiple feeds
+func (s *service) processJob(ctx context.Context, job *queue.Job) error {
+	var payload jobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &payload); err != nil {
+		return errors.Wrap(err, "decoding job payload")
+	}
+	if payload.Feed != "" {
+		f, ok := s.feedByName(payload.Feed)
+		if !ok {
+			// The feed was removed from the configuration since the job was queued, there's nothing to retry
+			level.Warn(s.l).Log("msg", "job for unknown feed, skipping", "job_id", job.ID, "feed", payload.Feed)
+			return nil
+		}
+		return s.process(ctx, f)
+	}
+	var failed []string
+	for i := range s.feeds {
+		if err := s.process(ctx, &s.feeds[i]); err != nil {
+			level.Error(s.l).Log("msg", "error processing feed", "feed", s.feeds[i].Name, "err", err)
+			failed = append(failed, s.feeds[i].Name)
+		}
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("processing feeds %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// execute runs the job, a panic in a provider or notifier is returned as an error instead of taking down the receiver
+func (s *service) execute(ctx context.Context, job *queue.Job) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			level.Error(s.l).Log("msg", "job panicked", "job_id", job.ID, "panic", r, "stack", string(debug.Stack()))
+			panicked, err = true, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	switch job.Kind {
+	case queue.KindProcessFeed:
+		return false, s.processJob(ctx, job)
+	default:
+		return false, fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+}
+
+// runJob processes a job with its own context, so a cancelled incoming request or a shutdown doesn't stop a job halfway
+// through posting. Failed jobs are retried with an exponential backoff, jobs that panicked are failed right away.
+func (s *service) runJob(job *queue.Job, worker int) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	level.Debug(s.l).Log("msg", "processing job", "worker", worker, "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	panicked, err := s.execute(ctx, job)
+	if err == nil {
+		if err := s.qr.Complete(job.ID); err != nil {
+			level.Error(s.l).Log("msg", "error completing job", "job_id", job.ID, "err", err)
+		}
+		return
+	}
+
+	// The same payload would most likely panic again, so there's no point in retrying it
+	if panicked || job.Attempts >= maxJobAttempts {
+		level.Error(s.l).Log("msg", "job failed, giving up", "job_id", job.ID, "attempt", job.Attempts, "err", err)
+		if err := s.qr.Fail(job.ID, err); err != nil {
+			level.Error(s.l).Log("msg", "error failing job", "job_id", job.ID, "err", err)
+		}
+		return
+	}
+	runAt := time.Now().Add(retryBackoff << (job.Attempts - 1))
+	level.Error(s.l).Log("msg", "job failed, retrying", "job_id", job.ID, "attempt", job.Attempts, "run_at", runAt, "err", err)
+	if err := s.qr.Retry(job.ID, err, runAt); err != nil {
+		level.Error(s.l).Log("msg", "error retrying job", "job_id", job.ID, "err", err)
+	}
+}
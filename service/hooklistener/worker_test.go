@@ -0,0 +1,141 @@
+package hooklistener
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dewey/webhook-receiver/notification"
+	"github.com/dewey/webhook-receiver/queue"
+	"github.com/go-kit/log"
+	"github.com/mmcdole/gofeed"
+)
+
+// fakeQueue records what happened to jobs
+type fakeQueue struct {
+	queue.Repository
+	completed []int64
+	retried   map[int64]time.Time
+	failed    []int64
+	errs      map[int64]error
+}
+
+func (q *fakeQueue) Complete(id int64) error {
+	q.completed = append(q.completed, id)
+	return nil
+}
+
+func (q *fakeQueue) Retry(id int64, jobErr error, runAt time.Time) error {
+	q.retried[id] = runAt
+	return nil
+}
+
+func (q *fakeQueue) Fail(id int64, jobErr error) error {
+	q.failed = append(q.failed, id)
+	if q.errs != nil {
+		q.errs[id] = jobErr
+	}
+	return nil
+}
+
+func Test_service_runJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		attempts    int
+		wantBackoff time.Duration
+		wantFailed  bool
+	}{
+		{name: "first retry", attempts: 1, wantBackoff: retryBackoff},
+		{name: "backoff doubles", attempts: 3, wantBackoff: 4 * retryBackoff},
+		{name: "out of attempts", attempts: maxJobAttempts, wantFailed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQueue{retried: make(map[int64]time.Time)}
+			s := &service{l: log.NewNopLogger(), qr: q}
+			start := time.Now()
+			// Jobs of unknown kinds always fail
+			s.runJob(&queue.Job{ID: 1, Kind: "unknown", Attempts: tt.attempts}, 0)
+
+			if len(q.completed) > 0 {
+				t.Fatal("runJob() completed a failing job")
+			}
+			if tt.wantFailed {
+				if len(q.failed) != 1 || len(q.retried) > 0 {
+					t.Errorf("runJob() failed %v and retried %v, want the job failed", q.failed, q.retried)
+				}
+				return
+			}
+			runAt, ok := q.retried[1]
+			if !ok {
+				t.Fatal("runJob() didn't retry the job")
+			}
+			if backoff := runAt.Sub(start); backoff < tt.wantBackoff || backoff > tt.wantBackoff+time.Second {
+				t.Errorf("runJob() retried after %v, want %v", backoff, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+// panickingFeeds panics on every fetch, like a provider or notifier with a bug would
+type panickingFeeds struct {
+	fakeFeeds
+}
+
+func (*panickingFeeds) Feed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	panic("bad payload")
+}
+
+func Test_service_runJob_panic(t *testing.T) {
+	q := &fakeQueue{retried: make(map[int64]time.Time), errs: make(map[int64]error)}
+	s := NewService(log.NewNopLogger(), &panickingFeeds{}, []Feed{
+		{Name: "default", URL: "https://example.com/index.xml", Notifiers: notification.Notifiers{&recordingNotifier{name: "mastodon"}}},
+	}, newTestCache(t), q, nil, nil, PostingOptions{DefaultCadence: mustCadence(t, "posts=5;per=day")})
+
+	s.runJob(&queue.Job{ID: 1, Kind: queue.KindProcessFeed, Payload: `{"feed":"default"}`, Attempts: 1}, 0)
+
+	if len(q.failed) != 1 || len(q.retried) > 0 || len(q.completed) > 0 {
+		t.Fatalf("runJob() failed %v, retried %v and completed %v, want the job failed", q.failed, q.retried, q.completed)
+	}
+	if err := q.errs[1]; err == nil || !strings.Contains(err.Error(), "bad payload") {
+		t.Errorf("runJob() failed the job with %v, want the panic value", err)
+	}
+}
+
+func Test_service_processJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		wantBlog    []string
+		wantPodcast []string
+	}{
+		{name: "feed", payload: `{"feed":"podcast"}`, wantPodcast: []string{"guid-1"}},
+		// Jobs from before we supported multiple feeds only have the event
+		{name: "legacy job", payload: `{"event":{"Kind":"pipeline","Status":"success"}}`, wantBlog: []string{"guid-1"}, wantPodcast: []string{"guid-1"}},
+		{name: "unknown feed", payload: `{"feed":"removed"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feeds := &fakeFeeds{items: map[string][]string{}}
+			feeds.set("https://example.com/index.xml", "guid-1")
+			feeds.set("https://example.com/podcast.xml", "guid-1")
+			blog := &recordingNotifier{name: "mastodon"}
+			podcast := &recordingNotifier{name: "mastodon"}
+			s := NewService(log.NewNopLogger(), feeds, []Feed{
+				{Name: "default", URL: "https://example.com/index.xml", Notifiers: notification.Notifiers{blog}},
+				{Name: "podcast", URL: "https://example.com/podcast.xml", Notifiers: notification.Notifiers{podcast}},
+			}, newTestCache(t), nil, nil, nil, PostingOptions{DefaultCadence: mustCadence(t, "posts=5;per=day")})
+
+			if err := s.processJob(context.Background(), &queue.Job{ID: 1, Kind: queue.KindProcessFeed, Payload: tt.payload}); err != nil {
+				t.Fatal(err)
+			}
+			if got := blog.Posted(); !equalStrings(got, tt.wantBlog) {
+				t.Errorf("default feed posted %v, want %v", got, tt.wantBlog)
+			}
+			if got := podcast.Posted(); !equalStrings(got, tt.wantPodcast) {
+				t.Errorf("podcast feed posted %v, want %v", got, tt.wantPodcast)
+			}
+		})
+	}
+}